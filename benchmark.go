@@ -0,0 +1,168 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// BenchmarkOptions controls how long a Benchmark runs. The run stops
+// at whichever limit is reached first.
+type BenchmarkOptions struct {
+	// Points to send, 0 means 100,000
+	Points int
+	// Duration to run for, 0 means no time limit
+	Duration time.Duration
+}
+
+// FullnessSample is the DAC buffer fullness at a point in the run
+type FullnessSample struct {
+	At     time.Duration
+	Points uint16
+}
+
+// LatencyStats summarizes ACK round trip times
+type LatencyStats struct {
+	Count int
+	Min   time.Duration
+	Max   time.Duration
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+}
+
+// BenchmarkReport is the result of a Benchmark run
+type BenchmarkReport struct {
+	Points          int
+	Frames          int
+	Elapsed         time.Duration
+	PointsPerSecond float64
+	// ProducerTime is spent waiting on the FrameSource
+	ProducerTime time.Duration
+	// NetworkTime is spent waiting on command replies
+	NetworkTime time.Duration
+	// WaitTime is the rest, mostly waiting for buffer space
+	WaitTime time.Duration
+	Latency  LatencyStats
+	Fullness []FullnessSample
+	// BufferLatency is the mean time points spent in the DAC
+	// buffer, the latency achieved in low-latency mode
+	BufferLatency time.Duration
+	Underflows    int
+}
+
+func (r BenchmarkReport) String() string {
+	return fmt.Sprintf("%v points in %v frames took %v (%.0f pps)\n", r.Points, r.Frames, r.Elapsed, r.PointsPerSecond) +
+		fmt.Sprintf("Producer: %v, network: %v, waiting: %v\n", r.ProducerTime, r.NetworkTime, r.WaitTime) +
+		fmt.Sprintf("ACK latency: min %v, mean %v, p50 %v, p90 %v, p99 %v, max %v (%d replies)\n",
+			r.Latency.Min, r.Latency.Mean, r.Latency.P50, r.Latency.P90, r.Latency.P99, r.Latency.Max, r.Latency.Count) +
+		fmt.Sprintf("Buffer: %d samples, mean latency %v, underflows: %d", len(r.Fullness), r.BufferLatency, r.Underflows)
+}
+
+// Benchmark plays src on the DAC and measures throughput and latency.
+// It works the same against hardware or an Emulator and returns once
+// the limits in opts are reached, the source ends or playback fails.
+func Benchmark(ctx context.Context, d *DAC, src FrameSource, opts BenchmarkOptions) (*BenchmarkReport, error) {
+	if opts.Points == 0 {
+		opts.Points = 100000
+	}
+
+	r := &BenchmarkReport{}
+	var rtts []time.Duration
+	t0 := time.Now()
+	p0 := d.PointsPlayed
+
+	onStatus, onUnderflow, onResponse := d.OnStatus, d.OnUnderflow, d.OnResponse
+	defer func() {
+		d.OnStatus, d.OnUnderflow, d.OnResponse = onStatus, onUnderflow, onResponse
+	}()
+	var buffered time.Duration
+	d.OnStatus = func(st *DACStatus) {
+		r.Fullness = append(r.Fullness, FullnessSample{time.Since(t0), st.BufferFullness})
+		buffered += d.BufferLatency()
+		if onStatus != nil {
+			onStatus(st)
+		}
+	}
+	d.OnUnderflow = func(st *DACStatus) {
+		r.Underflows++
+		if onUnderflow != nil {
+			onUnderflow(st)
+		}
+	}
+	d.OnResponse = func(cmd byte, rtt time.Duration) {
+		rtts = append(rtts, rtt)
+		r.NetworkTime += rtt
+		if onResponse != nil {
+			onResponse(cmd, rtt)
+		}
+	}
+
+	err := d.PlayFrames(ctx, FrameSourceFunc(func(ctx context.Context) (Frame, error) {
+		if d.PointsPlayed-p0 >= opts.Points {
+			return Frame{}, io.EOF
+		}
+		if opts.Duration > 0 && time.Since(t0) >= opts.Duration {
+			return Frame{}, io.EOF
+		}
+		tp := time.Now()
+		f, err := src.NextFrame(ctx)
+		r.ProducerTime += time.Since(tp)
+		if err == nil {
+			r.Frames++
+		}
+		return f, err
+	}))
+
+	r.Elapsed = time.Since(t0)
+	r.Points = d.PointsPlayed - p0
+	r.PointsPerSecond = float64(r.Points) / r.Elapsed.Seconds()
+	r.WaitTime = r.Elapsed - r.ProducerTime - r.NetworkTime
+	r.Latency = latencyStats(rtts)
+	if len(r.Fullness) > 0 {
+		r.BufferLatency = buffered / time.Duration(len(r.Fullness))
+	}
+	return r, err
+}
+
+func latencyStats(rtts []time.Duration) LatencyStats {
+	ls := LatencyStats{Count: len(rtts)}
+	if len(rtts) == 0 {
+		return ls
+	}
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+
+	var sum time.Duration
+	for _, rtt := range rtts {
+		sum += rtt
+	}
+	pct := func(p float64) time.Duration {
+		return rtts[int(p*float64(len(rtts)-1))]
+	}
+	ls.Min = rtts[0]
+	ls.Max = rtts[len(rtts)-1]
+	ls.Mean = sum / time.Duration(len(rtts))
+	ls.P50 = pct(0.50)
+	ls.P90 = pct(0.90)
+	ls.P99 = pct(0.99)
+	return ls
+}
@@ -0,0 +1,149 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// edproxy lets several programs share one Ether Dream. It listens for
+// them as a DAC would and plays their mix to the real one.
+//
+//	go run cmd/edproxy/main.go -dac 192.168.1.20 -policy priority -listen :7765,:7766 -priority :7766=10
+//
+// Then point each program at the proxy with NewDACPort("127.0.0.1", "7765"),
+// or port 7766 for the one that should take over.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tgreiser/etherdream"
+)
+
+var listen = flag.String("listen", ":7765", "TCP addresses to listen for clients on, comma separated.")
+var host = flag.String("dac", "", "Ether Dream to play to, the first found when empty.")
+var policy = flag.String("policy", "exclusive", "Who plays: exclusive, priority or zones.")
+var priorities = flag.String("priority", "", "Client priorities for -policy priority, by IP or listening port, like 10.0.0.5=10,:7766=5.")
+
+func main() {
+	flag.Parse()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pol, ok := etherdream.ParseProxyPolicy(*policy)
+	if !ok {
+		log.Fatalf("Unknown policy %q\n", *policy)
+	}
+	prio, err := parsePriorities(*priorities)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	addr := *host
+	if addr == "" {
+		log.Printf("Looking for a DAC...\n")
+		a, _, err := etherdream.FindFirstDAC()
+		if err != nil {
+			log.Fatal(err)
+		}
+		addr = a.IP.String()
+	}
+	dac, err := etherdream.NewDAC(addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dac.Close()
+
+	addrs := strings.Split(*listen, ",")
+	p, err := etherdream.NewProxy(strings.TrimSpace(addrs[0]), pol)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer p.Close()
+	p.Priorities = prio
+	log.Printf("Proxying %v to %v (%v)\n", p.Addr(), addr, *policy)
+	for _, a := range addrs[1:] {
+		la, err := p.Listen(strings.TrimSpace(a))
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Also listening on %v\n", la)
+	}
+
+	go report(ctx, p)
+	for {
+		err := dac.PlayFrames(ctx, p)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Playback stopped: %v\n", err)
+		time.Sleep(time.Second)
+		if err := dac.Reconnect(); err != nil {
+			log.Printf("Reconnect %v: %v\n", addr, err)
+		}
+	}
+}
+
+// parsePriorities reads ip=level and :port=level pairs
+func parsePriorities(s string) (map[string]int, error) {
+	ret := map[string]int{}
+	for _, kv := range strings.Split(s, ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		ip, level, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("priority %q is not ip=level or :port=level", kv)
+		}
+		n, err := strconv.Atoi(level)
+		if err != nil {
+			return nil, fmt.Errorf("priority %q: %w", kv, err)
+		}
+		ret[strings.TrimSpace(ip)] = n
+	}
+	return ret, nil
+}
+
+// report logs clients as they come, go and take the air
+func report(ctx context.Context, p *etherdream.Proxy) {
+	last := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second):
+		}
+		var b strings.Builder
+		for _, c := range p.Clients() {
+			state := "idle"
+			if c.OnAir {
+				state = "on air"
+			} else if c.Playing {
+				state = "waiting"
+			}
+			fmt.Fprintf(&b, " %v %v;", c.Addr, state)
+		}
+		if s := b.String(); s != last {
+			log.Printf("Clients:%v\n", s)
+			last = s
+		}
+	}
+}
@@ -0,0 +1,184 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// idnbridge listens as an IDN device and relays what it receives to one
+// or more Ether Dreams, so any software that speaks IDN can drive them.
+// Points keep the timing they were sent with, a change of scan rate is
+// queued on the DACs to land on the right point.
+//
+//	go run cmd/idnbridge/main.go -dac 192.168.1.20,192.168.1.21
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/etherdream/idn"
+)
+
+var listen = flag.String("listen", fmt.Sprintf(":%d", idn.Port), "UDP address to listen for IDN on.")
+var name = flag.String("name", "etherdream bridge", "Name to answer IDN scans with.")
+var hosts = flag.String("dac", "", "Comma separated Ether Dreams to play to, the first found when empty.")
+var tolerance = flag.Float64("rate-tolerance", 0.005, "Scan rate changes smaller than this fraction are ignored.")
+var minPoints = flag.Int("min-points", 200, "Points to measure before setting the first scan rate.")
+
+// rateWindow is how much play time the scan rate is measured over
+const rateWindow = 100 * time.Millisecond
+
+func main() {
+	flag.Parse()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	r, err := idn.NewReceiver(*listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer r.Close()
+	r.SetName(*name)
+	log.Printf("Listening for IDN on %v\n", r.Addr())
+
+	dacs, err := connect()
+	if err != nil {
+		log.Fatal(err)
+	}
+	outs := make([]etherdream.Output, len(dacs))
+	for iX, d := range dacs {
+		outs[iX] = d
+	}
+	out := etherdream.Tee(outs...)
+	defer out.Close()
+
+	src := &relay{r: r}
+	for {
+		err := etherdream.Play(ctx, out, src)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil || src.err != nil {
+			// the receiver is closed, there is nothing more to play
+			log.Printf("IDN receiver stopped: %v\n", src.err)
+			return
+		}
+		log.Printf("Playback stopped: %v\n", err)
+		time.Sleep(time.Second)
+		for _, d := range dacs {
+			if err := d.Reconnect(); err != nil {
+				log.Printf("Reconnect %v: %v\n", d.Host, err)
+			}
+		}
+	}
+}
+
+// connect opens the DACs named by -dac or the first one found
+func connect() ([]*etherdream.DAC, error) {
+	var names []string
+	for _, h := range strings.Split(*hosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			names = append(names, h)
+		}
+	}
+	if len(names) == 0 {
+		log.Printf("Looking for a DAC...\n")
+		addr, _, err := etherdream.FindFirstDAC()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, addr.IP.String())
+	}
+
+	var dacs []*etherdream.DAC
+	for _, h := range names {
+		d, err := etherdream.NewDAC(h)
+		if err != nil {
+			for _, d := range dacs {
+				d.Close()
+			}
+			return nil, fmt.Errorf("%v: %w", h, err)
+		}
+		log.Printf("Relaying to %v\n", h)
+		dacs = append(dacs, d)
+	}
+	return dacs, nil
+}
+
+// relay turns each IDN chunk into a frame at the sender's rate. Chunk
+// durations are in whole microseconds, too coarse to time a short chunk
+// by, so the rate is measured over rateWindow of chunks and only
+// changes when it moves by more than the tolerance. The starting rate
+// is measured over the first -min-points points, which are held back
+// until it is known.
+type relay struct {
+	r     *idn.Receiver
+	rate  int
+	meter idn.RateMeter
+	held  []etherdream.Point
+	// err is set when the receiver fails, rather than a DAC
+	err error
+}
+
+func (s *relay) NextFrame(ctx context.Context) (etherdream.Frame, error) {
+	for {
+		c, err := s.r.NextChunk(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.err = err
+			}
+			return etherdream.Frame{}, err
+		}
+		s.meter.Add(c)
+		if s.meter.Duration() >= rateWindow || s.rate == 0 && s.meter.Points() >= *minPoints {
+			s.measure()
+		}
+		if s.rate == 0 {
+			s.held = append(s.held, c.Points...)
+			continue
+		}
+		pts := c.Points
+		if s.held != nil {
+			pts, s.held = append(s.held, pts...), nil
+		}
+		return etherdream.Frame{Points: pts, Rate: s.rate}, nil
+	}
+}
+
+// measure sets the rate from the chunks since the last measurement
+func (s *relay) measure() {
+	rate := s.meter.Rate()
+	s.meter.Reset()
+	if rate <= 0 {
+		// chunks with no play time, there's nothing to go by
+		if s.rate == 0 {
+			s.rate = *etherdream.ScanRate
+		}
+		return
+	}
+	if s.rate == 0 || math.Abs(float64(rate-s.rate)) > *tolerance*float64(s.rate) {
+		if s.rate != 0 {
+			log.Printf("Scan rate %v\n", rate)
+		}
+		s.rate = rate
+	}
+}
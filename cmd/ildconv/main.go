@@ -0,0 +1,91 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// ildconv converts a recorded point stream, the raw 18 byte points a
+// PointStream writes, to an ILDA file.
+//
+//	go run cmd/ildconv/main.go -in stream.pts -out stream.ild
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"math"
+	"os"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/etherdream/ilda"
+)
+
+var in = flag.String("in", "", "Recorded point stream to read.")
+var out = flag.String("out", "", "ILDA file to write.")
+var format = flag.Int("format", ilda.Format2DTrueColor, "ILDA format to write, 4 (3D) or 5 (2D true color).")
+var framePoints = flag.Int("frame-points", 0, "Points per frame, defaults to scan-rate / 30.")
+var name = flag.String("name", "", "Frame name stored in the ILDA headers.")
+var company = flag.String("company", "", "Company name stored in the ILDA headers.")
+
+func main() {
+	flag.Parse()
+	if *framePoints == 0 {
+		*framePoints = etherdream.FramePoints()
+	}
+
+	r, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer r.Close()
+
+	w, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer w.Close()
+
+	iw, err := ilda.NewWriter(w, byte(*format))
+	if err != nil {
+		log.Fatal(err)
+	}
+	iw.Name = *name
+	iw.Company = *company
+
+	src := etherdream.ReaderSource(r, *framePoints)
+	frames, err := copyFrames(iw, src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = iw.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote %v frames to %v\n", frames, *out)
+}
+
+func copyFrames(iw *ilda.Writer, src etherdream.FrameSource) (int, error) {
+	ctx := context.Background()
+	for iX := 0; iX < math.MaxUint16; iX++ {
+		f, err := src.NextFrame(ctx)
+		if err == io.EOF {
+			return iX, nil
+		}
+		if err != nil {
+			return iX, err
+		}
+		iw.WriteFrame(f)
+	}
+	return math.MaxUint16, nil
+}
@@ -0,0 +1,139 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// laserexport runs a registered generator without a DAC and saves what it
+// draws as an animated GIF or a numbered PNG sequence, timed as the DAC
+// would play it.
+//
+//	go run cmd/laserexport/main.go -list
+//	go run cmd/laserexport/main.go -gen circle -gif circle.gif -duration 3s
+//	go run cmd/laserexport/main.go -gen cube -png out/frame%05d.png -fps 60
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"image/color"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/tgreiser/etherdream"
+	_ "github.com/tgreiser/etherdream/generators"
+	"github.com/tgreiser/etherdream/preview"
+)
+
+var list = flag.Bool("list", false, "List the generators and exit.")
+var gen = flag.String("gen", "", "Name of the generator to run.")
+var gifOut = flag.String("gif", "", "Animated GIF file to write.")
+var pngOut = flag.String("png", "", "PNG file name pattern for the image number, like frame%05d.png.")
+var duration = flag.Duration("duration", 0, "Length of the export, defaults to 5s.")
+var fps = flag.Int("fps", 30, "Images per second of a PNG sequence.")
+var size = flag.String("size", "512x512", "Width and height of the images, WxH.")
+var bg = flag.String("bg", "000000", "Background color as hex RGB.")
+var beam = flag.Float64("beam", 1.5, "Beam width in pixels.")
+var glow = flag.Float64("glow", 4, "Glow radius in pixels, 0 for none.")
+var blank = flag.Bool("blank", false, "Draw the blanked moves too.")
+var galvo = flag.Float64("galvo", 0, "Simulate galvos of this bandwidth in Hz, 0 for perfect mirrors.")
+
+func main() {
+	flag.Parse()
+	if *list {
+		for _, g := range etherdream.Generators() {
+			fmt.Printf("%-12v %v\n", g.Name, g.Description)
+		}
+		return
+	}
+	g, ok := etherdream.LookupGenerator(*gen)
+	if !ok {
+		log.Fatalf("No generator named %q, see -list", *gen)
+	}
+	if *gifOut == "" && *pngOut == "" {
+		log.Fatal("Nothing to write, give -gif or -png")
+	}
+
+	w, h, err := parseSize(*size)
+	if err != nil {
+		log.Fatal(err)
+	}
+	background, err := parseColor(*bg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ex := preview.Export{
+		Options: preview.Options{
+			Width:        w,
+			Height:       h,
+			BeamWidth:    *beam,
+			Glow:         *glow,
+			ShowBlanking: *blank,
+			Background:   background,
+		},
+		Duration: *duration,
+		FPS:      *fps,
+	}
+
+	ctx := context.Background()
+	if *gifOut != "" {
+		if *galvo > 0 {
+			ex.Galvo = preview.NewGalvo(*galvo, 0)
+		}
+		src := etherdream.NewStreamSource(g.Stream)
+		err := preview.SaveGIF(ctx, *gifOut, src, ex)
+		src.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Wrote %v\n", *gifOut)
+	}
+	if *pngOut != "" {
+		if *galvo > 0 {
+			ex.Galvo = preview.NewGalvo(*galvo, 0)
+		}
+		src := etherdream.NewStreamSource(g.Stream)
+		n, err := preview.SavePNGSequence(ctx, src, *pngOut, ex)
+		src.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Wrote %v images\n", n)
+	}
+}
+
+func parseSize(s string) (int, int, error) {
+	ws, hs, ok := strings.Cut(s, "x")
+	if !ok {
+		hs = ws
+	}
+	w, err := strconv.Atoi(ws)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad -size %q: %v", s, err)
+	}
+	h, err := strconv.Atoi(hs)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad -size %q: %v", s, err)
+	}
+	return w, h, nil
+}
+
+func parseColor(s string) (color.Color, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(s, "#")) != 6 {
+		return nil, fmt.Errorf("bad -bg %q, use hex RGB like 101820", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, nil
+}
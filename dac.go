@@ -20,10 +20,12 @@ package etherdream
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"os"
 	"runtime"
@@ -37,6 +39,9 @@ var mut = &sync.Mutex{}
 // ScanRate controls the playback speed of the ether dream
 var ScanRate = flag.Int("scan-rate", 24000, "Number of points per second to play back.")
 
+// Latency is the default target for low-latency mode
+var Latency = flag.Int("latency", 0, "Target buffered latency in milliseconds, 0 plays whole frames.")
+
 // Assuming the ether dream scans 30 times per second
 var frameRate = 30
 
@@ -53,9 +58,14 @@ func whenToPlay() int {
 }
 
 // ProtocolError indicates a protocol level error. I've
-// never seen one, but maybe you will.
+// never seen one, but maybe you will. Status is set when
+// the DAC replied with a NAK, it is the status sent along
+// with the NAK, and Resp is the kind of NAK.
 type ProtocolError struct {
-	Msg string
+	Msg    string
+	Status *DACStatus
+	// Resp is the response code of a NAK, like RespNAKInvalid
+	Resp byte
 }
 
 func (e *ProtocolError) Error() string {
@@ -70,35 +80,160 @@ type DAC struct {
 	Port           string
 	FirmwareString string
 	LastStatus     *DACStatus
-	Reader         io.Reader
-	Writer         io.WriteCloser
 	PointsPlayed   int
-	buf            bytes.Buffer
-	conn           net.Conn
+
+	// Reader is the read end of the pipe NewDAC sets up.
+	//
+	// Deprecated: Play hands each stream a writer of its own,
+	// nothing reads this pipe any more.
+	Reader io.Reader
+	// Writer is the write end of the pipe NewDAC sets up.
+	//
+	// Deprecated: see Reader.
+	Writer io.WriteCloser
+
+	// TargetLatency turns on low-latency mode when set. Rather than
+	// waiting for room for a whole frame, playback keeps the DAC
+	// buffer near PointRate x TargetLatency with small, frequent
+	// data packets. Defaults to the -latency flag.
+	TargetLatency time.Duration
+
+	// Logger receives diagnostics. When nil, slog.Default() is
+	// used, or a debug level text logger if -debug is set.
+	Logger *slog.Logger
+
+	// OnStatus is called with every status reply from the DAC.
+	OnStatus func(*DACStatus)
+	// OnError is called with every error playback runs into.
+	// Errors that stop playback are also returned by Play.
+	OnError func(error)
+	// OnUnderflow is called when the DAC reports that its
+	// buffer ran dry during playback.
+	OnUnderflow func(*DACStatus)
+	// OnEStop is called when the light engine enters the
+	// E-Stop state.
+	OnEStop func(*DACStatus)
+	// OnResponse is called with the round trip time of every
+	// reply to a command, ACK or NAK.
+	OnResponse func(cmd byte, rtt time.Duration)
+
+	buf     bytes.Buffer
+	conn    net.Conn
+	started bool
+	naks    int
+	rate    uint32
+	sent    time.Time
+	hmu     sync.Mutex
+	history statusHistory
+}
+
+var debugLogger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+func (d *DAC) log() *slog.Logger {
+	if d.Logger != nil {
+		return d.Logger
+	}
+	if *Debug {
+		return debugLogger
+	}
+	return slog.Default()
+}
+
+// fail reports err to OnError and hands it back
+func (d *DAC) fail(err error) error {
+	if d.OnError != nil {
+		d.OnError(err)
+	}
+	return err
+}
+
+// setStatus records st and fires the status hooks
+func (d *DAC) setStatus(st *DACStatus) {
+	prev := d.LastStatus
+	d.LastStatus = st
+	underflow := st.Underflow() && (prev == nil || !prev.Underflow())
+	estop := st.EStop() && (prev == nil || !prev.EStop())
+
+	d.hmu.Lock()
+	d.history.addSample(*st)
+	if underflow {
+		d.history.underflows++
+	}
+	if estop {
+		d.history.estops++
+	}
+	d.hmu.Unlock()
+
+	if d.OnStatus != nil {
+		d.OnStatus(st)
+	}
+	if underflow {
+		d.log().Warn("DAC buffer underflow", "points", d.PointsPlayed)
+		if d.OnUnderflow != nil {
+			d.OnUnderflow(st)
+		}
+	}
+	if estop {
+		d.log().Warn("DAC emergency stop", "flags", st.LightEngineFlags)
+		if d.OnEStop != nil {
+			d.OnEStop(st)
+		}
+	}
 }
 
 // NewDAC will connect to an Ether Dream device over TCP
 // or it will return an error
 func NewDAC(host string) (*DAC, error) {
+	return NewDACPort(host, "7765")
+}
+
+// NewDACPort connects to a DAC listening on a port other
+// than the standard 7765, like an Emulator.
+func NewDACPort(host, port string) (*DAC, error) {
 	if !flag.Parsed() {
 		flag.Parse()
 	}
 	// connect to the DAC over TCP
 	r, w := io.Pipe()
-	dac := &DAC{Host: host, Port: "7765", Reader: r, Writer: w}
+	dac := &DAC{
+		Host:          host,
+		Port:          port,
+		Reader:        r,
+		Writer:        w,
+		TargetLatency: time.Duration(*Latency) * time.Millisecond,
+	}
 	err := dac.init()
+	if err == nil {
+		register(dac)
+	}
 	return dac, err
 }
 
 // Close the network connection, you should. -- Yoda
-func (d *DAC) Close() {
-	d.conn.Close()
+func (d *DAC) Close() error {
+	unregister(d)
+	return d.conn.Close()
 }
 
-func (d *DAC) init() error {
-	if *Debug {
-		fmt.Println("Connecting to TCP")
+// Reconnect drops the network connection and connects again,
+// use it to resume after a network error.
+func (d *DAC) Reconnect() error {
+	if d.conn != nil {
+		d.conn.Close()
 	}
+	d.buf.Reset()
+	d.started = false
+	d.naks = 0
+
+	d.hmu.Lock()
+	d.history.reconnects++
+	d.hmu.Unlock()
+
+	return d.init()
+}
+
+func (d *DAC) init() error {
+	d.log().Debug("Connecting to TCP", "host", d.Host, "port", d.Port)
 	c, err := net.DialTimeout("tcp", d.Host+":"+d.Port, time.Second*15)
 	if err != nil {
 		return err
@@ -119,9 +254,7 @@ func (d *DAC) init() error {
 	}
 
 	d.FirmwareString = strings.TrimSpace(strings.Replace(string(by), "\x00", " ", -1))
-	if *Debug {
-		fmt.Printf("Firmware: %v\n", d.FirmwareString)
-	}
+	d.log().Debug("Firmware", "version", d.FirmwareString)
 
 	return nil
 }
@@ -143,27 +276,44 @@ func (d *DAC) Read(l int) ([]byte, error) {
 func (d *DAC) ReadResponse(cmd string) (*DACStatus, error) {
 	data, err := d.Read(22)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
 		return nil, err
 	}
 
 	resp := data[0]
 	cmdR := data[1]
 	status := NewDACStatus(data[2:])
-	//fmt.Printf("\nRead response: Resp=%s Cmd=%s Status=%s\n", string(resp), string(cmdR), status.String())
+	// the greeting on connect was not asked for, so has no round trip
+	if !d.sent.IsZero() {
+		rtt := time.Since(d.sent)
+		d.sent = time.Time{}
+		d.hmu.Lock()
+		d.history.addRTT(rtt)
+		if resp != RespACK {
+			d.history.naks++
+		}
+		d.hmu.Unlock()
+		if d.OnResponse != nil {
+			d.OnResponse(cmdR, rtt)
+		}
+	}
 
 	if cmdR != []byte(cmd)[0] {
-		return nil, &ProtocolError{fmt.Sprintf("Expected resp for %s, got %s", cmd, string(cmdR))}
+		return nil, &ProtocolError{Msg: fmt.Sprintf("Expected resp for %s, got %s", cmd, string(cmdR))}
 	}
+	d.setStatus(status)
 	if resp != []byte("a")[0] {
-		return nil, &ProtocolError{fmt.Sprintf("Expected ACK, got %s Resp=%s\n%s", string(cmdR), string(resp), status.String())}
+		return nil, &ProtocolError{
+			Msg:    fmt.Sprintf("Expected ACK, got %s Resp=%s\n%s", string(cmdR), string(resp), status.String()),
+			Status: status,
+			Resp:   resp,
+		}
 	}
-	d.LastStatus = status
 	return status, nil
 }
 
 // Send a command to the DAC
-func (d DAC) Send(cmd []byte) error {
+func (d *DAC) Send(cmd []byte) error {
+	d.sent = time.Now()
 	_, err := d.conn.Write(cmd)
 	return err
 }
@@ -187,8 +337,33 @@ func (d *DAC) Begin(lwm uint16, rate uint32) (*DACStatus, error) {
 		return nil, err
 	}
 
-	s, err := d.ReadResponse(string(BeginCmd))
-	fmt.Printf("Begin: %v\n\n", s)
+	s, err := d.ReadResponse(string(rune(BeginCmd)))
+	if err == nil {
+		d.hmu.Lock()
+		d.history.requested = rate
+		d.hmu.Unlock()
+		d.log().Debug("Begin", "status", s)
+	}
+	return s, err
+}
+
+// QueueRate queues a point rate change. It takes effect at the next
+// point sent with the RateChange flag.
+func (d *DAC) QueueRate(rate uint32) (*DACStatus, error) {
+	var cmd = make([]byte, 5)
+	cmd[0] = 'q'
+	binary.LittleEndian.PutUint32(cmd[1:5], rate)
+
+	if err := d.Send(cmd); err != nil {
+		return nil, err
+	}
+
+	s, err := d.ReadResponse("q")
+	if err == nil {
+		d.hmu.Lock()
+		d.history.requested = rate
+		d.hmu.Unlock()
+	}
 	return s, err
 }
 
@@ -213,9 +388,7 @@ func (d *DAC) Write(b []byte) (*DACStatus, error) {
 	cmd[0] = 'd'
 	binary.LittleEndian.PutUint16(cmd[1:3], l/PointSize)
 	copy(cmd[3:], b)
-	if *Debug {
-		fmt.Printf("DAC Write %v points\n", l/PointSize)
-	}
+	d.log().Debug("DAC Write", "points", l/PointSize)
 
 	if err := d.Send(cmd); err != nil {
 		return nil, err
@@ -280,107 +453,246 @@ func (d *DAC) Ping() (*DACStatus, error) {
 
 // ShouldPrepare or not? State 1 and 2 are good. Some Flags
 // need prepare to reset an invalid state.
-func (d DAC) ShouldPrepare() bool {
-	return d.LastStatus.PlaybackState == 0 ||
-		d.LastStatus.PlaybackFlags&2 == 2 ||
-		d.LastStatus.PlaybackFlags&4 == 4
+func (d *DAC) ShouldPrepare() bool {
+	return d.LastStatus.PlaybackState == PlaybackIdle ||
+		d.LastStatus.Underflow() ||
+		d.LastStatus.PlaybackFlags&PlaybackFlagEStop != 0
 }
 
-// Measure how long it takes to play 10,000 points
-func (d *DAC) Measure(stream PointStream) {
-	*Debug = true
-	t0 := time.Now()
+// Play a stream generator and begin sending output to the laser.
+// Play returns when the stream is closed or playback fails.
+func (d *DAC) Play(stream PointStream) error {
+	src := NewStreamSource(stream)
+	defer src.Close()
 
-	go d.Play(stream)
+	return d.PlayFrames(context.Background(), src)
+}
 
-	for {
-		if d.PointsPlayed >= 100000 {
-			t1 := time.Now()
-			fmt.Printf("%v took %v\n", d.PointsPlayed, t1.Sub(t0).String())
-			os.Exit(0)
+// PlayChannel plays the frames received on frames until the channel is
+// closed.
+func (d *DAC) PlayChannel(frames <-chan Frame) error {
+	return d.PlayFrames(context.Background(), ChannelSource(frames))
+}
+
+// PlayFrames sends frames from src to the laser until the source returns
+// io.EOF or ctx is done. Any other error from the source or the DAC is
+// returned.
+func (d *DAC) PlayFrames(ctx context.Context, src FrameSource) error {
+	d.started = false
+	return Play(ctx, d, FrameSourceFunc(func(ctx context.Context) (Frame, error) {
+		f, err := src.NextFrame(ctx)
+		if err != nil && err != io.EOF {
+			err = d.fail(err)
 		}
-		runtime.Gosched()
-	}
+		return f, err
+	}))
 }
 
-// Play a stream generator and begin sending output to the laser
-func (d *DAC) Play(stream PointStream) {
-	// First, prepare the stream
-	if d.LastStatus.PlaybackState == 2 {
-		if *Debug {
-			fmt.Printf("Error: Already playing?!")
+// WriteFrame queues the points of f, preparing the DAC first when
+// playback hasn't begun. It makes the DAC an Output.
+func (d *DAC) WriteFrame(ctx context.Context, f Frame) error {
+	if !d.started {
+		if err := d.prepare(); err != nil {
+			return err
 		}
+	}
+	return d.writeFrame(ctx, f)
+}
+
+// prepare readies the stream, unless the DAC is already playing
+func (d *DAC) prepare() error {
+	if d.LastStatus.PlaybackState == PlaybackPlaying {
+		d.log().Warn("DAC already playing")
 	} else if d.ShouldPrepare() {
 		st, err := d.Prepare()
 		if err != nil {
-			fmt.Printf("ERROR: Failed to prepare: %v\n\n", err)
-		}
-		if *Debug {
-			fmt.Printf("DAC prepared: %v\n\n", st)
+			return d.fail(fmt.Errorf("prepare: %w", err))
 		}
+		d.log().Debug("DAC prepared", "status", st)
 	}
+	return nil
+}
 
-	started := 0
-	// Start stream
-	go stream(d.Writer)
-
-OuterLoop:
-	for {
-		// Read calls from the pipe
-		cap := 1799 - d.LastStatus.BufferFullness
-		by := make([]byte, FramePoints()*int(PointSize))
-		idx := 0
-		when := whenToPlay()
+// Status is the last status reply from the DAC
+func (d *DAC) Status() DACStatus {
+	d.hmu.Lock()
+	defer d.hmu.Unlock()
+	s, _ := d.history.latest()
+	return s.Status
+}
 
-		if *Debug {
-			fmt.Printf("Buffer capacity: %v is lessThan: %v\n", cap, when)
+// writeFrame queues the points of one frame in chunks as buffer space
+// becomes available
+func (d *DAC) writeFrame(ctx context.Context, f Frame) error {
+	pending := f.Points
+	if f.Rate > 0 && d.started && uint32(f.Rate) != d.rate && len(pending) > 0 {
+		if _, err := d.QueueRate(uint32(f.Rate)); err != nil {
+			if err = d.handleNak(err); err != nil {
+				return err
+			}
+		} else {
+			// the change lands on the first point of the frame
+			pending = append([]Point(nil), pending...)
+			pending[0].Flags |= RateChange
+			d.rate = uint32(f.Rate)
 		}
-
-		if int(cap) <= when {
-			time.Sleep(time.Millisecond * 5)
-			d.Ping()
-			continue
+	}
+	for len(pending) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		fp := FramePoints()
-		for idx < fp {
-			bdx := idx * int(PointSize)
-			_, err := d.Reader.Read(by[bdx:])
-			if err != nil {
-				if err == io.EOF {
-					break OuterLoop
-				}
-				fmt.Printf("Error playing stream: %v", err)
-				break
+		n, wait := d.room(len(pending))
+		if n == 0 {
+			time.Sleep(wait)
+			if _, err := d.Ping(); err != nil {
+				return d.fail(err)
 			}
-			idx++
+			continue
 		}
 
+		by := Frame{Points: pending[:n]}.Encode()
+
 		mut.Lock()
 		st, err := d.Write(by)
+		mut.Unlock()
 		if err != nil {
-			fmt.Printf("ERROR: %v\n", err)
+			if err = d.handleNak(err); err != nil {
+				return err
+			}
+			continue
 		}
-
-		d.PointsPlayed += len(by) / int(PointSize)
-		if *Debug {
-			fmt.Printf("Points: %v\nStatus: %v\n", d.PointsPlayed, st)
+		pending = pending[n:]
+		if d.started {
+			d.naks = 0
 		}
 
-		if started == 0 {
-			st, err := d.Begin(0, uint32(*ScanRate))
-			if err != nil {
-				fmt.Printf("ERROR on Begin: %v\n\n", err)
+		d.PointsPlayed += n
+		d.hmu.Lock()
+		d.history.points = d.PointsPlayed
+		d.hmu.Unlock()
+		d.log().Debug("Points", "played", d.PointsPlayed, "status", st)
+
+		if !d.started {
+			rate := d.rate
+			if rate == 0 {
+				rate = uint32(*ScanRate)
 			}
-			started = 1
-			if *Debug {
-				fmt.Printf("\nBegin executed: %v\n", st)
+			if f.Rate > 0 {
+				rate = uint32(f.Rate)
 			}
+			st, err := d.Begin(0, rate)
+			if err != nil {
+				if err = d.handleNak(err); err != nil {
+					return err
+				}
+				continue
+			}
+			d.started = true
+			d.naks = 0
+			d.rate = rate
+			d.log().Debug("Begin executed", "status", st)
 		}
 
-		mut.Unlock()
 		runtime.Gosched()
 	}
+	return nil
+}
+
+// room decides how many of the pending points to send next. When it is
+// 0, wait is how long to sleep before asking the DAC again.
+func (d *DAC) room(pending int) (n int, wait time.Duration) {
+	full := int(d.LastStatus.BufferFullness)
+	cap := bufferSize - full
+
+	if rate := d.pointRate(); d.TargetLatency > 0 && rate > 0 {
+		// keep the buffer near the setpoint, sending no less
+		// than a millisecond of points at a time
+		chunk := rate / 1000
+		if chunk < 1 {
+			chunk = 1
+		}
+		target := int(float64(rate) * d.TargetLatency.Seconds())
+		if target < chunk {
+			target = chunk
+		}
+		n = target - full
+		if n < chunk {
+			return 0, time.Duration(chunk-n) * time.Second / time.Duration(rate)
+		}
+	} else {
+		when := whenToPlay()
+		d.log().Debug("Buffer capacity", "capacity", cap, "lessThan", when)
+		if cap <= when {
+			return 0, time.Millisecond * 5
+		}
+		n = FramePoints()
+	}
+
+	if n > pending {
+		n = pending
+	}
+	if n > cap {
+		n = cap
+	}
+	return n, 0
+}
+
+// pointRate is the rate the DAC reports, or the -scan-rate before
+// playback has begun
+func (d *DAC) pointRate() int {
+	if d.LastStatus != nil && d.LastStatus.PointRate > 0 {
+		return int(d.LastStatus.PointRate)
+	}
+	return *ScanRate
+}
+
+// BufferLatency is how long the points currently in the DAC buffer
+// will take to play, the latency achieved by the last status reply.
+// It is 0 when the rate isn't known.
+func (d *DAC) BufferLatency() time.Duration {
+	rate := d.pointRate()
+	if d.LastStatus == nil || rate <= 0 {
+		return 0
+	}
+	return time.Duration(d.LastStatus.BufferFullness) * time.Second / time.Duration(rate)
+}
+
+// maxNaks is how many NAKs in a row playback retries before giving up
+const maxNaks = 8
+
+// handleNak decides if playback can go on after err. A NAK after an
+// underflow is handled by preparing the DAC again. Other NAKs are
+// retried after a growing pause, up to maxNaks in a row. Errors that
+// aren't NAKs and stop conditions are terminal and returned.
+func (d *DAC) handleNak(err error) error {
+	d.fail(err)
+	pe, ok := err.(*ProtocolError)
+	if !ok || pe.Status == nil || pe.Status.EStop() || pe.Resp == RespNAKStopCond {
+		return err
+	}
+	if pe.Status.PlaybackState == PlaybackIdle {
+		if _, err := d.Prepare(); err != nil {
+			return d.fail(fmt.Errorf("prepare: %w", err))
+		}
+		d.started = false
+		d.naks = 0
+		return nil
+	}
+
+	d.naks++
+	if d.naks > maxNaks {
+		d.naks = 0
+		return fmt.Errorf("gave up after %d NAKs: %w", maxNaks, err)
+	}
+	// 5ms doubling to 200ms, the DAC sent its status with the NAK so
+	// room() sees the buffer as it is
+	wait := 5 * time.Millisecond << (d.naks - 1)
+	if wait > 200*time.Millisecond {
+		wait = 200 * time.Millisecond
+	}
+	time.Sleep(wait)
+	return nil
 }
 
 // FindFirstDAC starts a UDP server to listen for broadcast packets on your network. Return the UDPAddr
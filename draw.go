@@ -49,7 +49,10 @@ func FramePoints() int {
 	return (*ScanRate) / frameRate
 }
 
-// NextFrame advances playback ... add some blank points
+// NextFrame advances playback ... add some blank points. When w is a
+// FrameWriter the frame boundary is passed along with EndFrame. It
+// returns -1 when w takes no more frames, a closed StreamSource or a
+// full file, and the stream should return.
 func NextFrame(w io.WriteCloser, pointsPlayed int, last Point) int {
 	times := FramePoints() - pointsPlayed
 	by := NewPoint(int(last.X), int(last.Y), BlankColor).Encode()
@@ -64,6 +67,11 @@ func NextFrame(w io.WriteCloser, pointsPlayed int, last Point) int {
 	if *Dump {
 		fmt.Printf("---- %v x %v\t%v\t%v\t%v\t%v\n", times, last.X, last.Y, 0, 0, 0)
 	}
+	if fw, ok := w.(FrameWriter); ok {
+		if err := fw.EndFrame(); err != nil {
+			return -1
+		}
+	}
 	frameCount++
 	return frameCount
 }
@@ -101,6 +109,56 @@ func BlankPath(w io.WriteCloser, p ln.Path) *Point {
 	return pt
 }
 
+// ColorPath is an ln.Path with the color to draw it in
+type ColorPath struct {
+	Path  ln.Path
+	Color color.Color
+}
+
+// ColorPaths is a list of colored paths, drawn in order
+type ColorPaths []ColorPath
+
+// DrawPaths draws every segment of each path with DrawPath, blanking
+// between paths that don't join up and back to the start at the end. It
+// returns how many points were written and the last one, ready for
+// NextFrame.
+func DrawPaths(w io.WriteCloser, paths ColorPaths, drawSpeed float64) (int, Point) {
+	cw := &countWriter{w: w}
+	var last Point
+	for iX, cp := range paths {
+		if len(cp.Path) == 0 {
+			continue
+		}
+		for jX := 0; jX+1 < len(cp.Path); jX++ {
+			DrawPath(cw, ln.Path{cp.Path[jX], cp.Path[jX+1]}, cp.Color, drawSpeed)
+		}
+		from := cp.Path[len(cp.Path)-1]
+		last = *NewPoint(int(from.X), int(from.Y), cp.Color)
+		next := paths[(iX+1)%len(paths)].Path
+		if len(next) > 0 && from.Distance(next[0]) > 0 {
+			if pt := BlankPath(cw, ln.Path{from, next[0]}); pt != nil {
+				last = *pt
+			}
+		}
+	}
+	return cw.n, last
+}
+
+// countWriter counts the points written through it
+type countWriter struct {
+	w io.WriteCloser
+	n int
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	c.n += len(b) / int(PointSize)
+	return c.w.Write(b)
+}
+
+func (c *countWriter) Close() error {
+	return c.w.Close()
+}
+
 // Osc is an oscillator value - send it frame counts / point counts
 func Osc(cur, max int, amplitude, frequency, offset float64) float64 {
 	rad := float64(cur+1) / float64(max) * 2.0 * math.Pi
@@ -0,0 +1,94 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package dxf
+
+import (
+	"image/color"
+	"math"
+)
+
+// the first nine AutoCAD Color Index entries have their own colors
+var aciBase = []color.RGBA{
+	{0, 0, 0, 255},
+	{255, 0, 0, 255},
+	{255, 255, 0, 255},
+	{0, 255, 0, 255},
+	{0, 255, 255, 255},
+	{0, 0, 255, 255},
+	{255, 0, 255, 255},
+	{255, 255, 255, 255},
+	{128, 128, 128, 255},
+	{192, 192, 192, 255},
+}
+
+// 250 to 255 are a gray ramp
+var aciGrays = []uint8{51, 80, 105, 130, 190, 255}
+
+// ACI brightness for each pair in a block of ten
+var aciValues = []float64{1, 0.65, 0.5, 0.3, 0.15}
+
+// ACIColor converts an AutoCAD Color Index to RGB. Entries 10 to 249
+// step around the hue circle 15 degrees at a time, each hue in five
+// brightness levels at full and half saturation.
+func ACIColor(i int) color.RGBA {
+	// layers that are switched off store a negative color
+	if i < 0 {
+		i = -i
+	}
+	switch {
+	case i < len(aciBase):
+		return aciBase[i]
+	case i >= 250 && i <= 255:
+		g := aciGrays[i-250]
+		return color.RGBA{g, g, g, 255}
+	case i > 255:
+		return aciBase[7]
+	}
+
+	hue := float64(i/10-1) * 15
+	v := aciValues[(i%10)/2]
+	s := 1.0
+	if i%2 == 1 {
+		s = 0.5
+	}
+	return hsv(hue, s, v)
+}
+
+func hsv(h, s, v float64) color.RGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g = c, x
+	case h < 120:
+		r, g = x, c
+	case h < 180:
+		g, b = c, x
+	case h < 240:
+		g, b = x, c
+	case h < 300:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	to8 := func(f float64) uint8 {
+		return uint8(math.Round((f + m) * 255))
+	}
+	return color.RGBA{to8(r), to8(g), to8(b), 255}
+}
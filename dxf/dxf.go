@@ -0,0 +1,212 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package dxf reads ASCII DXF drawings from CAD software as colored ln
+// paths in DAC coordinates. LINE, LWPOLYLINE, POLYLINE, ARC, CIRCLE and
+// SPLINE entities are supported, with colors taken from the entity or
+// its layer.
+package dxf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// Options for reading a drawing
+type Options struct {
+	// Scale is the number of DAC units per drawing unit. It must be
+	// set, DXF files often don't say what their units are.
+	Scale float64
+	// OriginX and OriginY, in drawing units, map to DAC 0, 0
+	OriginX float64
+	OriginY float64
+	// Layers to read, all visible layers when empty
+	Layers []string
+	// LayerColors overrides the color of every entity on a layer
+	LayerColors map[string]color.Color
+	// Tolerance is the furthest arcs and splines may stray from the
+	// real curve, in DAC units. Defaults to 40.
+	Tolerance float64
+}
+
+// ErrNoScale is returned when Options.Scale is not set
+var ErrNoScale = errors.New("dxf: Options.Scale must be set")
+
+// pair is one group code and its value
+type pair struct {
+	code  int
+	value string
+}
+
+// entity is the group codes from one 0 code to the next
+type entity struct {
+	kind  string
+	pairs []pair
+}
+
+func (e entity) str(code int) string {
+	for _, p := range e.pairs {
+		if p.code == code {
+			return p.value
+		}
+	}
+	return ""
+}
+
+func (e entity) float(code int) float64 {
+	return parse(e.str(code))
+}
+
+func (e entity) int(code int) (int, bool) {
+	s := e.str(code)
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.Atoi(s)
+	return v, err == nil
+}
+
+// floats returns every value for code in order
+func (e entity) floats(code int) []float64 {
+	var ret []float64
+	for _, p := range e.pairs {
+		if p.code == code {
+			ret = append(ret, parse(p.value))
+		}
+	}
+	return ret
+}
+
+// parse reads a number, bad values read as 0
+func parse(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// layer from the LAYER table
+type layer struct {
+	color  int
+	hidden bool
+}
+
+// Load reads the named DXF file
+func Load(name string, opts Options) (etherdream.ColorPaths, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f, opts)
+}
+
+// Parse reads an ASCII DXF drawing from r
+func Parse(r io.Reader, opts Options) (etherdream.ColorPaths, error) {
+	if opts.Scale == 0 {
+		return nil, ErrNoScale
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = 40
+	}
+
+	sections, err := readSections(r)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := map[string]layer{}
+	for _, e := range sections["TABLES"] {
+		if e.kind != "LAYER" {
+			continue
+		}
+		c, _ := e.int(62)
+		flags, _ := e.int(70)
+		layers[e.str(2)] = layer{color: c, hidden: c < 0 || flags&1 != 0}
+	}
+
+	conv := &converter{opts: opts, layers: layers}
+	ents := sections["ENTITIES"]
+	for iX := 0; iX < len(ents); iX++ {
+		e := ents[iX]
+		if !conv.wanted(e) {
+			continue
+		}
+		if e.kind == "POLYLINE" {
+			// the vertices follow as their own entities
+			var verts []entity
+			for iX+1 < len(ents) && ents[iX+1].kind == "VERTEX" {
+				iX++
+				verts = append(verts, ents[iX])
+			}
+			conv.polyline(e, verts)
+			continue
+		}
+		conv.entity(e)
+	}
+	return conv.paths, nil
+}
+
+// readSections splits the file into the entities of each section
+func readSections(r io.Reader) (map[string][]entity, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	sections := map[string][]entity{}
+
+	var section string
+	var cur *entity
+	line := 0
+	for sc.Scan() {
+		line++
+		codeStr := strings.TrimSpace(sc.Text())
+		if !sc.Scan() {
+			return nil, fmt.Errorf("dxf: missing value for group code at line %d", line)
+		}
+		line++
+		value := strings.TrimSpace(sc.Text())
+		code, err := strconv.Atoi(codeStr)
+		if err != nil {
+			return nil, fmt.Errorf("dxf: bad group code %q at line %d, only ASCII DXF is supported", codeStr, line-1)
+		}
+
+		switch {
+		case code == 2 && cur == nil && section == "":
+			// the name follows 0 SECTION
+			section = value
+		case code != 0:
+			if cur != nil {
+				cur.pairs = append(cur.pairs, pair{code, value})
+			}
+		case value == "SECTION" || value == "ENDSEC":
+			section, cur = "", nil
+		case value == "EOF":
+			return sections, nil
+		case section != "":
+			sections[section] = append(sections[section], entity{kind: value})
+			cur = &sections[section][len(sections[section])-1]
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
@@ -0,0 +1,289 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package dxf
+
+import (
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/ln/ln"
+)
+
+// doc builds a DXF document from space separated codes and values
+func doc(tables, entities string) string {
+	s := ""
+	if tables != "" {
+		s += "0 SECTION 2 TABLES " + tables + " 0 ENDSEC "
+	}
+	s += "0 SECTION 2 ENTITIES " + entities + " 0 ENDSEC 0 EOF"
+	return strings.Join(strings.Fields(s), "\n") + "\n"
+}
+
+func load(t *testing.T, tables, entities string, opts Options) etherdream.ColorPaths {
+	t.Helper()
+	if opts.Scale == 0 {
+		opts.Scale = 100
+	}
+	paths, err := Parse(strings.NewReader(doc(tables, entities)), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+// onCircle checks every point of p is r from c, within the tolerance
+func onCircle(t *testing.T, name string, p ln.Path, c ln.Vector, r float64) {
+	t.Helper()
+	for _, v := range p {
+		if d := v.Distance(c); math.Abs(d-r) > 1e-6 {
+			t.Errorf("%v: point %v is %.1f from the centre, want %v", name, v, d, r)
+			return
+		}
+	}
+}
+
+func near(a, b ln.Vector) bool {
+	return a.Distance(b) < 1e-6
+}
+
+func TestReadSections(t *testing.T) {
+	sections, err := readSections(strings.NewReader(doc("0 LAYER 2 walls 62 3", "0 LINE 8 walls 10 1 0 POINT 10 2")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sections["TABLES"]; len(got) != 1 || got[0].kind != "LAYER" || got[0].str(2) != "walls" {
+		t.Errorf("tables %+v", got)
+	}
+	ents := sections["ENTITIES"]
+	if len(ents) != 2 || ents[0].kind != "LINE" || ents[1].kind != "POINT" {
+		t.Fatalf("entities %+v", ents)
+	}
+	if ents[0].str(8) != "walls" || ents[0].float(10) != 1 || ents[1].float(10) != 2 {
+		t.Errorf("group codes %+v", ents)
+	}
+
+	for _, bad := range []string{"0\nSECTION\n2", "zero\nSECTION\n"} {
+		if _, err := readSections(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q read, want an error", bad)
+		}
+	}
+	if _, err := Parse(strings.NewReader(doc("", "")), Options{}); err != ErrNoScale {
+		t.Errorf("no scale: err = %v, want ErrNoScale", err)
+	}
+}
+
+func TestLine(t *testing.T) {
+	paths := load(t, "", "0 LINE 10 1 20 1 11 2 21 3 0 LINE 10 0 20 0 11 1000 21 -1000",
+		Options{OriginX: 1, OriginY: 1})
+	if len(paths) != 2 {
+		t.Fatalf("%v paths, want 2", len(paths))
+	}
+	if want := (ln.Path{{X: 0, Y: 0}, {X: 100, Y: 200}}); !near(paths[0].Path[0], want[0]) || !near(paths[0].Path[1], want[1]) {
+		t.Errorf("line %v, want %v", paths[0].Path, want)
+	}
+	// off the edge is clamped to the DAC range
+	if got := paths[1].Path[1]; got.X != 32767 || got.Y != -32767 {
+		t.Errorf("clamped end %v, want 32767, -32767", got)
+	}
+}
+
+func TestPolylines(t *testing.T) {
+	paths := load(t, "", `
+		0 LWPOLYLINE 90 4 70 1 10 0 20 0 10 1 20 0 10 1 20 1 10 0 20 1
+		0 POLYLINE 70 0 0 VERTEX 10 0 20 0 0 VERTEX 10 2 20 0 0 VERTEX 10 2 20 2 0 SEQEND`, Options{})
+	if len(paths) != 2 {
+		t.Fatalf("%v paths, want 2", len(paths))
+	}
+	square := ln.Path{{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 100}, {X: 0, Y: 100}, {X: 0, Y: 0}}
+	if len(paths[0].Path) != len(square) {
+		t.Fatalf("closed LWPOLYLINE %v, want %v", paths[0].Path, square)
+	}
+	for iX, v := range square {
+		if !near(paths[0].Path[iX], v) {
+			t.Errorf("closed LWPOLYLINE %v, want %v", paths[0].Path, square)
+			break
+		}
+	}
+	if got := paths[1].Path; len(got) != 3 || !near(got[2], ln.Vector{X: 200, Y: 200}) {
+		t.Errorf("POLYLINE %v, want 3 points to 200, 200", got)
+	}
+}
+
+func TestBulge(t *testing.T) {
+	for _, c := range []struct {
+		bulge string
+		below bool
+	}{
+		// a half circle counter clockwise from 0, 0 to 10, 0 dips
+		// below the chord, clockwise rises above it
+		{"1", true},
+		{"-1", false},
+	} {
+		paths := load(t, "", "0 LWPOLYLINE 10 0 20 0 42 "+c.bulge+" 10 10 20 0", Options{})
+		if len(paths) != 1 {
+			t.Fatalf("bulge %v: %v paths, want 1", c.bulge, len(paths))
+		}
+		p := paths[0].Path
+		if len(p) < 5 {
+			t.Errorf("bulge %v: %v points, want an arc", c.bulge, len(p))
+		}
+		onCircle(t, "bulge "+c.bulge, p, ln.Vector{X: 500}, 500)
+		if !near(p[0], ln.Vector{}) || !near(p[len(p)-1], ln.Vector{X: 1000}) {
+			t.Errorf("bulge %v: runs %v to %v, want 0, 0 to 1000, 0", c.bulge, p[0], p[len(p)-1])
+		}
+		if mid := p[len(p)/2]; (mid.Y < 0) != c.below {
+			t.Errorf("bulge %v: middle at %v, want below %v", c.bulge, mid, c.below)
+		}
+	}
+}
+
+func TestArcs(t *testing.T) {
+	paths := load(t, "", `
+		0 ARC 10 0 20 0 40 10 50 0 51 90
+		0 ARC 10 0 20 0 40 10 50 270 51 90
+		0 CIRCLE 10 1 20 1 40 10`, Options{})
+	if len(paths) != 3 {
+		t.Fatalf("%v paths, want 3", len(paths))
+	}
+
+	quarter := paths[0].Path
+	onCircle(t, "quarter", quarter, ln.Vector{}, 1000)
+	if !near(quarter[0], ln.Vector{X: 1000}) || !near(quarter[len(quarter)-1], ln.Vector{Y: 1000}) {
+		t.Errorf("quarter runs %v to %v", quarter[0], quarter[len(quarter)-1])
+	}
+	// no chord strays more than the default tolerance of 40
+	for iX := 1; iX < len(quarter); iX++ {
+		mid := quarter[iX].Add(quarter[iX-1]).MulScalar(0.5)
+		if sag := 1000 - mid.Length(); sag > 40 {
+			t.Errorf("chord %v sags %.1f", iX, sag)
+		}
+	}
+
+	// counter clockwise through 0 degrees, not back the long way
+	wrap := paths[1].Path
+	onCircle(t, "wrap", wrap, ln.Vector{}, 1000)
+	if mid := wrap[len(wrap)/2]; !near(mid, ln.Vector{X: 1000}) {
+		t.Errorf("arc from 270 to 90 passes %v, want 1000, 0", mid)
+	}
+
+	circle := paths[2].Path
+	onCircle(t, "circle", circle, ln.Vector{X: 100, Y: 100}, 1000)
+	if !near(circle[0], circle[len(circle)-1]) {
+		t.Errorf("circle is not closed")
+	}
+}
+
+func TestSpline(t *testing.T) {
+	paths := load(t, "", `
+		0 SPLINE 71 1 40 0 40 0 40 1 40 2 40 2 10 0 20 0 10 1 20 0 10 1 20 1
+		0 SPLINE 71 2 40 0 40 0 40 0 40 1 40 1 40 1 10 0 20 0 10 1 20 2 10 2 20 0
+		0 SPLINE 11 0 21 0 11 1 21 1 11 2 21 0`, Options{})
+	if len(paths) != 3 {
+		t.Fatalf("%v paths, want 3", len(paths))
+	}
+
+	// degree 1 is the control polygon
+	for _, v := range paths[0].Path {
+		onLeg := math.Abs(v.Y) < 1e-6 && v.X >= -1e-6 && v.X <= 100+1e-6 ||
+			math.Abs(v.X-100) < 1e-6 && v.Y >= -1e-6 && v.Y <= 100+1e-6
+		if !onLeg {
+			t.Errorf("degree 1 spline point %v is off the control polygon", v)
+			break
+		}
+	}
+
+	// a clamped quadratic starts and ends on its end control points
+	// and peaks halfway up the middle one
+	q := paths[1].Path
+	if !near(q[0], ln.Vector{}) || !near(q[len(q)-1], ln.Vector{X: 200}) {
+		t.Errorf("quadratic runs %v to %v, want 0, 0 to 200, 0", q[0], q[len(q)-1])
+	}
+	if mid := q[len(q)/2]; !near(mid, ln.Vector{X: 100, Y: 100}) {
+		t.Errorf("quadratic peaks at %v, want 100, 100", mid)
+	}
+
+	// fit points only are joined up
+	want := ln.Path{{}, {X: 100, Y: 100}, {X: 200}}
+	if got := paths[2].Path; len(got) != 3 || !near(got[1], want[1]) || !near(got[2], want[2]) {
+		t.Errorf("fit points %v, want %v", got, want)
+	}
+}
+
+func TestACIColor(t *testing.T) {
+	for _, c := range []struct {
+		i    int
+		want color.RGBA
+	}{
+		{1, color.RGBA{255, 0, 0, 255}},
+		{5, color.RGBA{0, 0, 255, 255}},
+		{7, color.RGBA{255, 255, 255, 255}},
+		// a layer that is off stores the color negated
+		{-3, color.RGBA{0, 255, 0, 255}},
+		{10, color.RGBA{255, 0, 0, 255}},
+		{11, color.RGBA{255, 128, 128, 255}},
+		{12, color.RGBA{166, 0, 0, 255}},
+		{50, color.RGBA{255, 255, 0, 255}},
+		{250, color.RGBA{51, 51, 51, 255}},
+		{255, color.RGBA{255, 255, 255, 255}},
+		{300, color.RGBA{255, 255, 255, 255}},
+	} {
+		if got := ACIColor(c.i); got != c.want {
+			t.Errorf("ACIColor(%v) = %v, want %v", c.i, got, c.want)
+		}
+	}
+}
+
+func TestLayers(t *testing.T) {
+	tables := `0 LAYER 2 red 62 1 70 0 0 LAYER 2 off 62 -2 70 0
+		0 LAYER 2 frozen 62 3 70 1 0 LAYER 2 blue 62 5 70 0`
+	line := func(layer, extra string) string {
+		return "0 LINE 8 " + layer + " " + extra + " 10 0 20 0 11 1 21 0 "
+	}
+	ents := line("red", "") + line("red", "62 3") + line("red", "62 256") +
+		line("off", "") + line("frozen", "") + line("blue", "") + line("none", "")
+
+	paths := load(t, tables, ents, Options{})
+	want := []color.Color{
+		ACIColor(1), // from the layer
+		ACIColor(3), // the entity's own
+		ACIColor(1), // BYLAYER
+		ACIColor(5),
+		ACIColor(7), // no such layer
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("%v paths, want %v, hidden layers are skipped", len(paths), len(want))
+	}
+	for iX, c := range want {
+		if paths[iX].Color != c {
+			t.Errorf("line %v color %v, want %v", iX, paths[iX].Color, c)
+		}
+	}
+
+	over := color.RGBA{1, 2, 3, 255}
+	paths = load(t, tables, ents, Options{Layers: []string{"red"}, LayerColors: map[string]color.Color{"red": over}})
+	if len(paths) != 3 {
+		t.Fatalf("%v paths on layer red, want 3", len(paths))
+	}
+	for _, p := range paths {
+		if p.Color != over {
+			t.Errorf("color %v, want the override %v", p.Color, over)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package dxf
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/ln/ln"
+)
+
+// converter turns entities into paths in DAC coordinates
+type converter struct {
+	opts   Options
+	layers map[string]layer
+	paths  etherdream.ColorPaths
+}
+
+// wanted is false for entities on hidden or filtered out layers
+func (c *converter) wanted(e entity) bool {
+	name := e.str(8)
+	if name == "" {
+		name = "0"
+	}
+	if l, ok := c.layers[name]; ok && l.hidden {
+		return false
+	}
+	if len(c.opts.Layers) == 0 {
+		return true
+	}
+	for _, l := range c.opts.Layers {
+		if l == name {
+			return true
+		}
+	}
+	return false
+}
+
+// color picks the layer override, then the entity color, then the
+// layer color
+func (c *converter) color(e entity) color.Color {
+	name := e.str(8)
+	if name == "" {
+		name = "0"
+	}
+	if col, ok := c.opts.LayerColors[name]; ok {
+		return col
+	}
+	// 0 is BYBLOCK and 256 is BYLAYER
+	if i, ok := e.int(62); ok && i > 0 && i < 256 {
+		return ACIColor(i)
+	}
+	if l, ok := c.layers[name]; ok {
+		return ACIColor(l.color)
+	}
+	return ACIColor(7)
+}
+
+// vector maps a drawing point to DAC coordinates
+func (c *converter) vector(x, y float64) ln.Vector {
+	clamp := func(v float64) float64 {
+		return math.Max(-32767, math.Min(32767, v))
+	}
+	return ln.Vector{
+		X: clamp((x - c.opts.OriginX) * c.opts.Scale),
+		Y: clamp((y - c.opts.OriginY) * c.opts.Scale),
+	}
+}
+
+func (c *converter) add(e entity, path ln.Path) {
+	if len(path) < 2 {
+		return
+	}
+	c.paths = append(c.paths, etherdream.ColorPath{Path: path, Color: c.color(e)})
+}
+
+func (c *converter) entity(e entity) {
+	switch e.kind {
+	case "LINE":
+		c.add(e, ln.Path{
+			c.vector(e.float(10), e.float(20)),
+			c.vector(e.float(11), e.float(21)),
+		})
+	case "LWPOLYLINE":
+		flags, _ := e.int(70)
+		c.add(e, c.lwpolyline(e, flags&1 != 0))
+	case "ARC":
+		start := e.float(50) * math.Pi / 180
+		end := e.float(51) * math.Pi / 180
+		// arcs always run counter clockwise
+		for end <= start {
+			end += 2 * math.Pi
+		}
+		c.add(e, c.arc(e.float(10), e.float(20), e.float(40), start, end-start))
+	case "CIRCLE":
+		c.add(e, c.arc(e.float(10), e.float(20), e.float(40), 0, 2*math.Pi))
+	case "SPLINE":
+		c.add(e, c.spline(e))
+	}
+}
+
+// vertex is a polyline corner and the bulge of the segment leaving it
+type vertex struct {
+	x, y, bulge float64
+}
+
+// lwpolyline reads the repeated 10, 20 and 42 codes in order
+func (c *converter) lwpolyline(e entity, closed bool) ln.Path {
+	var verts []vertex
+	for _, p := range e.pairs {
+		switch p.code {
+		case 10:
+			verts = append(verts, vertex{x: parse(p.value)})
+		case 20:
+			if len(verts) > 0 {
+				verts[len(verts)-1].y = parse(p.value)
+			}
+		case 42:
+			if len(verts) > 0 {
+				verts[len(verts)-1].bulge = parse(p.value)
+			}
+		}
+	}
+	return c.bulgePath(verts, closed)
+}
+
+// polyline joins the VERTEX entities that follow a POLYLINE
+func (c *converter) polyline(e entity, ents []entity) {
+	flags, _ := e.int(70)
+	// 3D meshes and polyface meshes aren't outlines
+	if flags&(16|64) != 0 {
+		return
+	}
+	verts := make([]vertex, 0, len(ents))
+	for _, v := range ents {
+		vf, _ := v.int(70)
+		// spline frame control points
+		if vf&16 != 0 {
+			continue
+		}
+		verts = append(verts, vertex{v.float(10), v.float(20), v.float(42)})
+	}
+	c.add(e, c.bulgePath(verts, flags&1 != 0))
+}
+
+// bulgePath draws straight or bulged segments between vertices. The
+// bulge is the tangent of a quarter of the arc's included angle,
+// negative for clockwise.
+func (c *converter) bulgePath(verts []vertex, closed bool) ln.Path {
+	if len(verts) == 0 {
+		return nil
+	}
+	if closed {
+		verts = append(verts, verts[0])
+	}
+	path := ln.Path{c.vector(verts[0].x, verts[0].y)}
+	for iX := 1; iX < len(verts); iX++ {
+		a, b := verts[iX-1], verts[iX]
+		if a.bulge == 0 {
+			path = append(path, c.vector(b.x, b.y))
+			continue
+		}
+		theta := 4 * math.Atan(a.bulge)
+		chord := math.Hypot(b.x-a.x, b.y-a.y)
+		if chord == 0 {
+			continue
+		}
+		r := chord / (2 * math.Sin(theta/2))
+		// the center sits off the chord midpoint, to the left for a
+		// counter clockwise bulge
+		mx, my := (a.x+b.x)/2, (a.y+b.y)/2
+		d := r * math.Cos(theta/2)
+		nx, ny := -(b.y-a.y)/chord, (b.x-a.x)/chord
+		cx, cy := mx+nx*d, my+ny*d
+		start := math.Atan2(a.y-cy, a.x-cx)
+		arc := c.arc(cx, cy, math.Abs(r), start, theta)
+		path = append(path, arc[1:]...)
+	}
+	return path
+}
+
+// arc samples a circular arc from start through sweep radians so no
+// chord strays more than the tolerance from the curve
+func (c *converter) arc(cx, cy, r, start, sweep float64) ln.Path {
+	n := c.steps(r, sweep)
+	path := make(ln.Path, 0, n+1)
+	for iX := 0; iX <= n; iX++ {
+		t := start + sweep*float64(iX)/float64(n)
+		path = append(path, c.vector(cx+r*math.Cos(t), cy+r*math.Sin(t)))
+	}
+	return path
+}
+
+func (c *converter) steps(r, sweep float64) int {
+	rd := math.Abs(r * c.opts.Scale)
+	n := 4
+	if rd > c.opts.Tolerance {
+		// the sagitta of a chord spanning angle a is r(1-cos(a/2))
+		a := 2 * math.Acos(1-c.opts.Tolerance/rd)
+		n = int(math.Ceil(math.Abs(sweep) / a))
+	}
+	if n < 4 {
+		n = 4
+	}
+	return n
+}
+
+// spline evaluates a NURBS curve with de Boor's algorithm. Splines with
+// only fit points are drawn through them.
+func (c *converter) spline(e entity) ln.Path {
+	flags, _ := e.int(70)
+	degree, _ := e.int(71)
+	knots := e.floats(40)
+	weights := e.floats(41)
+	xs, ys := e.floats(10), e.floats(20)
+	if len(ys) < len(xs) {
+		xs = xs[:len(ys)]
+	}
+
+	if len(xs) == 0 || len(knots) != len(xs)+degree+1 || degree < 1 {
+		fx, fy := e.floats(11), e.floats(21)
+		verts := make([]vertex, 0, len(fx))
+		for iX := 0; iX < len(fx) && iX < len(fy); iX++ {
+			verts = append(verts, vertex{x: fx[iX], y: fy[iX]})
+		}
+		return c.bulgePath(verts, flags&1 != 0)
+	}
+	if len(weights) != len(xs) {
+		weights = nil
+	}
+
+	// sample finely enough for the control polygon
+	var span float64
+	for iX := 1; iX < len(xs); iX++ {
+		span += math.Hypot(xs[iX]-xs[iX-1], ys[iX]-ys[iX-1])
+	}
+	n := int(math.Ceil(math.Abs(span*c.opts.Scale) / (c.opts.Tolerance * 4)))
+	if n < 8 {
+		n = 8
+	}
+	if n > 2000 {
+		n = 2000
+	}
+
+	lo, hi := knots[degree], knots[len(xs)]
+	path := make(ln.Path, 0, n+1)
+	for iX := 0; iX <= n; iX++ {
+		t := lo + (hi-lo)*float64(iX)/float64(n)
+		x, y := deBoor(t, degree, knots, xs, ys, weights)
+		path = append(path, c.vector(x, y))
+	}
+	return path
+}
+
+// deBoor evaluates the spline at t in homogeneous coordinates
+func deBoor(t float64, p int, knots, xs, ys, ws []float64) (float64, float64) {
+	// find the knot span holding t
+	k := p
+	for k < len(xs)-1 && t >= knots[k+1] {
+		k++
+	}
+
+	d := make([][3]float64, p+1)
+	for j := 0; j <= p; j++ {
+		w := 1.0
+		if ws != nil {
+			w = ws[j+k-p]
+		}
+		d[j] = [3]float64{xs[j+k-p] * w, ys[j+k-p] * w, w}
+	}
+	for r := 1; r <= p; r++ {
+		for j := p; j >= r; j-- {
+			den := knots[j+1+k-r] - knots[j+k-p]
+			a := 0.0
+			if den != 0 {
+				a = (t - knots[j+k-p]) / den
+			}
+			for iX := range d[j] {
+				d[j][iX] = (1-a)*d[j-1][iX] + a*d[j][iX]
+			}
+		}
+	}
+	if d[p][2] == 0 {
+		return d[p][0], d[p][1]
+	}
+	return d[p][0] / d[p][2], d[p][1] / d[p][2]
+}
@@ -0,0 +1,273 @@
+/*
+# Copyright 2016 Tim Greiser
+# Based on work by Jacob Potter, some comments are from his
+# protocol documents
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Response codes sent ahead of every status reply
+const (
+	RespACK         = 'a'
+	RespNAKFull     = 'F'
+	RespNAKInvalid  = 'I'
+	RespNAKStopCond = '!'
+)
+
+const firmwareStringLen = 32
+
+// Emulator is a local stand-in for an Ether Dream. It speaks the TCP
+// protocol, drains its buffer at the requested point rate and reports
+// status like the hardware does, so playback can be exercised without
+// a DAC.
+type Emulator struct {
+	// BufferCapacity is the number of points the emulated buffer holds
+	BufferCapacity int
+	// Firmware is returned for the 'v' command
+	Firmware string
+
+	ln       net.Listener
+	mu       sync.Mutex
+	st       DACStatus
+	buffered float64
+	played   float64
+	queued   []uint32
+	tick     time.Time
+	// onData gets the points of every accepted data command
+	onData func([]Point)
+}
+
+// NewEmulator starts an emulated DAC listening on addr, use
+// "127.0.0.1:0" to pick a free port.
+func NewEmulator(addr string) (*Emulator, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	e := &Emulator{
+		BufferCapacity: bufferSize,
+		Firmware:       "etherdream emulator",
+		ln:             ln,
+		tick:           time.Now(),
+	}
+	go e.serve()
+	return e, nil
+}
+
+// Addr is the address the emulator is listening on
+func (e *Emulator) Addr() *net.TCPAddr {
+	return e.ln.Addr().(*net.TCPAddr)
+}
+
+// Port is the listening port, ready for NewDACPort
+func (e *Emulator) Port() string {
+	return strconv.Itoa(e.Addr().Port)
+}
+
+// Close stops listening for connections
+func (e *Emulator) Close() error {
+	return e.ln.Close()
+}
+
+// Status returns the current emulated status
+func (e *Emulator) Status() DACStatus {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.advance()
+	e.st.BufferFullness = uint16(e.buffered + 0.5)
+	return e.st
+}
+
+func (e *Emulator) serve() {
+	for {
+		c, err := e.ln.Accept()
+		if err != nil {
+			return
+		}
+		go e.handle(c)
+	}
+}
+
+func (e *Emulator) handle(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+
+	// the DAC greets every new connection with a status reply
+	if _, err := c.Write(e.reply(RespACK, '?')); err != nil {
+		return
+	}
+	for {
+		cmd, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		resp, err := e.command(cmd, r)
+		if err != nil {
+			return
+		}
+		if _, err = c.Write(resp); err != nil {
+			return
+		}
+	}
+}
+
+// command reads the arguments for cmd and applies it
+func (e *Emulator) command(cmd byte, r *bufio.Reader) ([]byte, error) {
+	var args []byte
+	switch cmd {
+	case 'v':
+		fw := make([]byte, firmwareStringLen)
+		copy(fw, e.Firmware)
+		return fw, nil
+	case BeginCmd, 'u':
+		args = make([]byte, 6)
+	case 'q':
+		args = make([]byte, 4)
+	case 'd':
+		args = make([]byte, 2)
+	}
+	if _, err := io.ReadFull(r, args); err != nil {
+		return nil, err
+	}
+	var points []byte
+	if cmd == 'd' {
+		n := int(binary.LittleEndian.Uint16(args))
+		points = make([]byte, n*int(PointSize))
+		if _, err := io.ReadFull(r, points); err != nil {
+			return nil, err
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.advance()
+
+	resp := byte(RespNAKInvalid)
+	switch cmd {
+	case '?':
+		resp = RespACK
+	case 'p':
+		if e.st.LightEngineState == LightEngineEStop {
+			resp = RespNAKStopCond
+		} else if e.st.PlaybackState == PlaybackIdle {
+			e.st.PlaybackState = PlaybackPrepared
+			e.st.PlaybackFlags = 0
+			e.buffered = 0
+			e.queued = nil
+			resp = RespACK
+		}
+	case BeginCmd:
+		if e.st.PlaybackState == PlaybackPrepared && e.buffered > 0 {
+			e.st.PlaybackState = PlaybackPlaying
+			e.st.PointRate = binary.LittleEndian.Uint32(args[2:6])
+			resp = RespACK
+		}
+	case 'u':
+		if e.st.PlaybackState == PlaybackPlaying {
+			e.st.PointRate = binary.LittleEndian.Uint32(args[2:6])
+			resp = RespACK
+		}
+	case 'q':
+		if e.st.PlaybackState != PlaybackIdle {
+			e.queued = append(e.queued, binary.LittleEndian.Uint32(args))
+			resp = RespACK
+		}
+	case 'd':
+		n := len(points) / int(PointSize)
+		if e.st.PlaybackState == PlaybackIdle {
+			break
+		}
+		if int(e.buffered)+n > e.BufferCapacity {
+			resp = RespNAKFull
+			break
+		}
+		// rate changes take effect when the point arrives, not
+		// when it is played
+		for iX := 0; iX < n; iX++ {
+			flags := binary.LittleEndian.Uint16(points[iX*int(PointSize):])
+			if flags&RateChange != 0 && len(e.queued) > 0 {
+				e.st.PointRate = e.queued[0]
+				e.queued = e.queued[1:]
+			}
+		}
+		e.buffered += float64(n)
+		resp = RespACK
+		if e.onData != nil {
+			pts := make([]Point, n)
+			for iX := range pts {
+				pts[iX] = DecodePoint(points[iX*int(PointSize):])
+			}
+			e.onData(pts)
+		}
+	case 's':
+		if e.st.PlaybackState != PlaybackIdle {
+			e.st.PlaybackState = PlaybackIdle
+			e.buffered = 0
+			resp = RespACK
+		}
+	case 0xFF, 0x00:
+		e.st.LightEngineState = LightEngineEStop
+		e.st.PlaybackState = PlaybackIdle
+		e.st.PlaybackFlags |= PlaybackFlagEStop
+		e.buffered = 0
+		resp = RespACK
+	case 'c':
+		if e.st.LightEngineState == LightEngineEStop {
+			e.st.LightEngineState = LightEngineReady
+			e.st.PlaybackFlags &^= PlaybackFlagEStop
+			resp = RespACK
+		}
+	}
+	return e.status(resp, cmd), nil
+}
+
+// advance drains the buffer for the time passed since the last call
+func (e *Emulator) advance() {
+	now := time.Now()
+	if e.st.PlaybackState == PlaybackPlaying {
+		played := now.Sub(e.tick).Seconds() * float64(e.st.PointRate)
+		if played >= e.buffered {
+			played = e.buffered
+			e.st.PlaybackState = PlaybackIdle
+			e.st.PlaybackFlags |= PlaybackFlagUnderflow
+		}
+		e.buffered -= played
+		e.played += played
+		e.st.PointCount = uint32(e.played)
+	}
+	e.tick = now
+}
+
+func (e *Emulator) reply(resp, cmd byte) []byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.advance()
+	return e.status(resp, cmd)
+}
+
+func (e *Emulator) status(resp, cmd byte) []byte {
+	e.st.BufferFullness = uint16(e.buffered + 0.5)
+	return append([]byte{resp, cmd}, e.st.Encode()...)
+}
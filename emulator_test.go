@@ -0,0 +1,267 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPointRoundTrip(t *testing.T) {
+	p := Point{X: -32768, Y: 32767, R: 1, G: 0xffff, B: 3, I: 4, U1: 5, U2: 6, Flags: RateChange}
+	if got := DecodePoint(p.Encode()); got != p {
+		t.Errorf("DecodePoint(Encode()) = %+v, want %+v", got, p)
+	}
+
+	// I defaults to the brightest color
+	p = Point{R: 10, G: 300, B: 20}
+	if got := DecodePoint(p.Encode()).I; got != 300 {
+		t.Errorf("I = %v, want 300", got)
+	}
+}
+
+func TestStatusRoundTrip(t *testing.T) {
+	st := DACStatus{
+		LightEngineState: LightEngineReady,
+		PlaybackState:    PlaybackPlaying,
+		PlaybackFlags:    PlaybackFlagUnderflow,
+		BufferFullness:   1234,
+		PointRate:        30000,
+		PointCount:       987654,
+	}
+	if got := *NewDACStatus(st.Encode()); got != st {
+		t.Errorf("NewDACStatus(Encode()) = %+v, want %+v", got, st)
+	}
+}
+
+// emulated starts an emulator and connects a DAC to it
+func emulated(t *testing.T) (*Emulator, *DAC) {
+	t.Helper()
+	e, err := NewEmulator("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { e.Close() })
+	d, err := NewDACPort("127.0.0.1", e.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return e, d
+}
+
+func testFrame(n int) Frame {
+	f := Frame{Points: make([]Point, n)}
+	for iX := range f.Points {
+		f.Points[iX] = *NewPoint(iX, -iX, color.White)
+	}
+	return f
+}
+
+func TestEmulatorPlayback(t *testing.T) {
+	e, d := emulated(t)
+	if d.FirmwareString != e.Firmware {
+		t.Errorf("firmware %q, want %q", d.FirmwareString, e.Firmware)
+	}
+
+	var got []Point
+	e.mu.Lock()
+	e.onData = func(pts []Point) { got = append(got, pts...) }
+	e.mu.Unlock()
+
+	ctx := context.Background()
+	f := testFrame(FramePoints())
+	for iX := 0; iX < 3; iX++ {
+		if err := d.WriteFrame(ctx, f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st := e.Status()
+	if st.PlaybackState != PlaybackPlaying {
+		t.Errorf("playback state %v, want playing", st.PlaybackState)
+	}
+	if st.PointRate != uint32(*ScanRate) {
+		t.Errorf("point rate %v, want %v", st.PointRate, *ScanRate)
+	}
+	if d.Status().PlaybackState != PlaybackPlaying {
+		t.Errorf("DAC status %+v, want playing", d.Status())
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(got) != 3*len(f.Points) {
+		t.Fatalf("emulator received %v points, want %v", len(got), 3*len(f.Points))
+	}
+	for iX, p := range f.Points {
+		want := p
+		want.I = 0xffff
+		if got[iX] != want {
+			t.Fatalf("point %v = %+v, want %+v", iX, got[iX], want)
+		}
+	}
+}
+
+func TestEmulatorQueuedRate(t *testing.T) {
+	e, d := emulated(t)
+	ctx := context.Background()
+	if err := d.WriteFrame(ctx, testFrame(100)); err != nil {
+		t.Fatal(err)
+	}
+	f := testFrame(100)
+	f.Rate = 30000
+	if err := d.WriteFrame(ctx, f); err != nil {
+		t.Fatal(err)
+	}
+	if f.Points[0].Flags != 0 {
+		t.Errorf("WriteFrame changed the caller's points")
+	}
+	if st := e.Status(); st.PointRate != 30000 {
+		t.Errorf("point rate %v, want 30000", st.PointRate)
+	}
+}
+
+func TestEmulatorTargetLatency(t *testing.T) {
+	e, d := emulated(t)
+	d.TargetLatency = 20 * time.Millisecond
+	target := *ScanRate * 20 / 1000
+
+	// the fill level each time points arrive
+	var fills []float64
+	e.mu.Lock()
+	e.onData = func([]Point) { fills = append(fills, e.buffered) }
+	e.mu.Unlock()
+
+	ctx := context.Background()
+	for iX := 0; iX < 10; iX++ {
+		if err := d.WriteFrame(ctx, testFrame(FramePoints())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(fills) < 20 {
+		t.Fatalf("%v data packets, want small frequent ones", len(fills))
+	}
+	// never more than a millisecond of points over the setpoint, and
+	// near it on the whole. The end of a frame can leave it short.
+	slack := float64(*ScanRate / 1000)
+	sum := 0.0
+	for iX, f := range fills {
+		if f > float64(target)+slack {
+			t.Errorf("packet %v filled the buffer to %.0f, want at most %v", iX, f, target)
+		}
+		sum += f
+	}
+	if avg := sum / float64(len(fills)); avg < 0.8*float64(target) {
+		t.Errorf("average fill %.0f, want about %v", avg, target)
+	}
+}
+
+func TestEmulatorNakFull(t *testing.T) {
+	e, d := emulated(t)
+	e.mu.Lock()
+	e.BufferCapacity = 10
+	e.mu.Unlock()
+
+	start := time.Now()
+	err := d.WriteFrame(context.Background(), testFrame(100))
+	var pe *ProtocolError
+	if !errors.As(err, &pe) || pe.Resp != RespNAKFull {
+		t.Fatalf("err = %v, want a NAK full", err)
+	}
+	// retries back off rather than hammering the DAC
+	if time.Since(start) < 100*time.Millisecond {
+		t.Errorf("gave up after %v, want a backoff", time.Since(start))
+	}
+}
+
+func TestEmulatorNakGivesUp(t *testing.T) {
+	e, d := emulated(t)
+	e.mu.Lock()
+	e.BufferCapacity = 10
+	e.mu.Unlock()
+
+	err := d.WriteFrame(context.Background(), testFrame(100))
+	if err == nil || !strings.Contains(err.Error(), "gave up") {
+		t.Fatalf("err = %v, want giving up", err)
+	}
+	// the first try and maxNaks retries
+	if got := d.Health().NAKs; got != maxNaks+1 {
+		t.Errorf("%v NAKs, want %v", got, maxNaks+1)
+	}
+
+	// the count starts again for the next frame
+	e.mu.Lock()
+	e.BufferCapacity = bufferSize
+	e.mu.Unlock()
+	if err := d.WriteFrame(context.Background(), testFrame(100)); err != nil {
+		t.Errorf("after room is made: %v", err)
+	}
+}
+
+func TestEmulatorEStopNak(t *testing.T) {
+	e, d := emulated(t)
+	ctx := context.Background()
+	if err := d.WriteFrame(ctx, testFrame(100)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the E-Stop button on the DAC is pressed mid stream
+	e.mu.Lock()
+	e.st.LightEngineState = LightEngineEStop
+	e.st.PlaybackState = PlaybackIdle
+	e.st.PlaybackFlags |= PlaybackFlagEStop
+	e.buffered = 0
+	e.mu.Unlock()
+
+	err := d.WriteFrame(ctx, testFrame(100))
+	var pe *ProtocolError
+	if !errors.As(err, &pe) || !pe.Status.EStop() {
+		t.Fatalf("err = %v, want a NAK in E-Stop", err)
+	}
+	if got := d.Health().NAKs; got != 1 {
+		t.Errorf("%v NAKs, want 1, E-Stop is not retried", got)
+	}
+}
+
+func TestEmulatorEStop(t *testing.T) {
+	_, d := emulated(t)
+	if _, err := d.EmergencyStop(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := d.Prepare()
+	var pe *ProtocolError
+	if !errors.As(err, &pe) || pe.Resp != RespNAKStopCond {
+		t.Fatalf("prepare in E-Stop: err = %v, want a stop condition NAK", err)
+	}
+	if err := d.WriteFrame(context.Background(), testFrame(10)); err == nil {
+		t.Errorf("WriteFrame in E-Stop succeeded")
+	}
+
+	if _, err := d.ClearEmergencyStop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Prepare(); err != nil {
+		t.Errorf("prepare after clearing E-Stop: %v", err)
+	}
+}
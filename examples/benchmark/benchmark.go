@@ -0,0 +1,93 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"math"
+	"time"
+
+	"image/color"
+
+	"github.com/tgreiser/etherdream"
+)
+
+var emulate = flag.Bool("emulate", false, "Benchmark against a local emulated DAC instead of hardware.")
+var points = flag.Int("points", 100000, "Number of points to send.")
+
+func main() {
+	flag.Parse()
+
+	var dac *etherdream.DAC
+	var err error
+	if *emulate {
+		emu, err := etherdream.NewEmulator("127.0.0.1:0")
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer emu.Close()
+		log.Printf("Emulating DAC at %v\n", emu.Addr())
+		dac, err = etherdream.NewDACPort("127.0.0.1", emu.Port())
+	} else {
+		log.Printf("Listening...\n")
+		addr, _, ferr := etherdream.FindFirstDAC()
+		if ferr != nil {
+			log.Fatalf("Network error: %v", ferr)
+		}
+		log.Printf("Found DAC at %v\n", addr)
+		dac, err = etherdream.NewDAC(addr.IP.String())
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dac.Close()
+
+	src := etherdream.NewStreamSource(pointStream)
+	defer src.Close()
+
+	report, err := etherdream.Benchmark(context.Background(), dac, src, etherdream.BenchmarkOptions{
+		Points:   *points,
+		Duration: time.Minute,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Benchmark:\n%v\n", report)
+}
+
+func pointStream(w io.WriteCloser) {
+	defer w.Close()
+
+	pstep := etherdream.FramePoints()
+	c := color.RGBA{0x66, 0x33, 0x00, 0xFF}
+	rad := 10260.0
+
+	var pt *etherdream.Point
+	for {
+		for i := 0; i < pstep; i++ {
+			f := float64(i) / float64(pstep) * 2.0 * math.Pi
+			pt = etherdream.NewPoint(int(math.Cos(f)*rad), int(math.Sin(f)*rad), c)
+			w.Write(pt.Encode())
+		}
+		if etherdream.NextFrame(w, pstep, *pt) < 0 {
+			return
+		}
+	}
+}
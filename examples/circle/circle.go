@@ -19,14 +19,10 @@
 package main
 
 import (
-	"io"
 	"log"
 
-	"math"
-
-	"image/color"
-
 	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/etherdream/generators"
 )
 
 func main() {
@@ -44,40 +40,7 @@ func main() {
 	}
 	defer dac.Close()
 
-	dac.Play(pointStream)
-}
-
-func pointStream(w io.WriteCloser) {
-	defer w.Close()
-
-	// Don't use a low # of steps, 30 and below can damage galvos
-	// We'll use the number of points in a frame for optimal sampling
-	pstep := etherdream.FramePoints()
-	c := color.RGBA{0x66, 0x33, 0x00, 0xFF}
-	maxrad := 10260
-	rad := maxrad
-	grow := false
-
-	for {
-		if rad <= 1 {
-			grow = true
-		} else if rad >= maxrad {
-			grow = false
-		}
-		if grow {
-			rad += 100
-		} else {
-			rad -= 100
-		}
-		var pt *etherdream.Point
-		for i := 0; i < pstep; i++ {
-			f := float64(i) / float64(pstep) * 2.0 * math.Pi
-			x := int(math.Cos(f) * float64(rad))
-			y := int(math.Sin(f) * float64(rad))
-			pt = etherdream.NewPoint(x, y, c)
-			w.Write(pt.Encode())
-		}
-
-		_ = etherdream.NextFrame(w, pstep, *pt)
+	if err := dac.Play(generators.Circle); err != nil {
+		log.Fatal(err)
 	}
 }
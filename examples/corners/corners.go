@@ -0,0 +1,88 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"flag"
+	"image/color"
+	"io"
+	"log"
+	"math"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/ln/ln"
+)
+
+var profile = flag.String("profile", "", "JSON projector profile, the default profile when empty.")
+var uniform = flag.Bool("uniform", false, "Draw with DrawPaths instead, to compare the corners.")
+
+// star is a five pointed star, all sharp corners
+func star(r float64) ln.Path {
+	var p ln.Path
+	for iX := 0; iX <= 5; iX++ {
+		a := math.Pi/2 + float64(iX*2%5)*2*math.Pi/5
+		p = append(p, ln.Vector{X: r * math.Cos(a), Y: r * math.Sin(a)})
+	}
+	return p
+}
+
+func main() {
+	flag.Parse()
+
+	pr := etherdream.DefaultProfile()
+	if *profile != "" {
+		var err error
+		if pr, err = etherdream.LoadProfile(*profile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	paths := etherdream.ColorPaths{
+		{Path: star(15000), Color: color.RGBA{0x00, 0xff, 0x00, 0xff}},
+	}
+
+	log.Printf("Listening...\n")
+	addr, _, err := etherdream.FindFirstDAC()
+	if err != nil {
+		log.Fatalf("Network error: %v", err)
+	}
+
+	log.Printf("Found DAC at %v\n", addr)
+
+	dac, err := etherdream.NewDAC(addr.IP.String())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dac.Close()
+
+	if err := dac.Play(func(w io.WriteCloser) {
+		defer w.Close()
+		for {
+			var n int
+			var last etherdream.Point
+			if *uniform {
+				n, last = etherdream.DrawPaths(w, paths, 0.0)
+			} else {
+				n, last = pr.DrawPaths(w, paths)
+			}
+			if etherdream.NextFrame(w, n, last) < 0 {
+				return
+			}
+		}
+	}); err != nil {
+		log.Fatal(err)
+	}
+}
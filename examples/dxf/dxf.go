@@ -0,0 +1,79 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/etherdream/dxf"
+)
+
+var file = flag.String("file", "", "DXF file to draw.")
+var scale = flag.Float64("scale", 0, "DAC units per drawing unit, required.")
+var originX = flag.Float64("origin-x", 0, "Drawing X coordinate to place at the center.")
+var originY = flag.Float64("origin-y", 0, "Drawing Y coordinate to place at the center.")
+var layers = flag.String("layers", "", "Comma separated layers to draw, all visible layers when empty.")
+var tolerance = flag.Float64("tolerance", 40, "How far flattened arcs may stray, in DAC units.")
+
+func main() {
+	flag.Parse()
+
+	opts := dxf.Options{
+		Scale:     *scale,
+		OriginX:   *originX,
+		OriginY:   *originY,
+		Tolerance: *tolerance,
+	}
+	if *layers != "" {
+		opts.Layers = strings.Split(*layers, ",")
+	}
+	paths, err := dxf.Load(*file, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Loaded %v paths from %v\n", len(paths), *file)
+
+	log.Printf("Listening...\n")
+	addr, _, err := etherdream.FindFirstDAC()
+	if err != nil {
+		log.Fatalf("Network error: %v", err)
+	}
+
+	log.Printf("Found DAC at %v\n", addr)
+
+	dac, err := etherdream.NewDAC(addr.IP.String())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dac.Close()
+
+	if err := dac.Play(func(w io.WriteCloser) {
+		defer w.Close()
+		for {
+			n, last := etherdream.DrawPaths(w, paths, 0.0)
+			if etherdream.NextFrame(w, n, last) < 0 {
+				return
+			}
+		}
+	}); err != nil {
+		log.Fatal(err)
+	}
+}
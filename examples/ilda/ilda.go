@@ -0,0 +1,58 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/etherdream/ilda"
+)
+
+var file = flag.String("file", "", "ILDA file (.ild) to play.")
+var fps = flag.Int("fps", 30, "Frames per second to play the file at.")
+var loop = flag.Bool("loop", true, "Repeat the file when it ends.")
+
+func main() {
+	flag.Parse()
+
+	frames, err := ilda.ReadFile(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Read %v frames from %v\n", len(frames), *file)
+
+	log.Printf("Listening...\n")
+	addr, _, err := etherdream.FindFirstDAC()
+	if err != nil {
+		log.Fatalf("Network error: %v", err)
+	}
+
+	log.Printf("Found DAC at %v\n", addr)
+
+	dac, err := etherdream.NewDAC(addr.IP.String())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dac.Close()
+
+	if err := dac.PlayFrames(context.Background(), ilda.Source(frames, *fps, *loop)); err != nil {
+		log.Fatal(err)
+	}
+}
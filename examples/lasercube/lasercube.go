@@ -0,0 +1,65 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"github.com/tgreiser/etherdream"
+	_ "github.com/tgreiser/etherdream/generators"
+	"github.com/tgreiser/etherdream/lasercube"
+)
+
+var gen = flag.String("gen", "circle", "Name of the generator to run, see laserexport -list.")
+var host = flag.String("host", "", "LaserCube to play to, the first one found when empty.")
+
+func main() {
+	flag.Parse()
+	g, ok := etherdream.LookupGenerator(*gen)
+	if !ok {
+		log.Fatalf("No generator named %q", *gen)
+	}
+
+	if *host == "" {
+		log.Printf("Scanning...\n")
+		cubes, err := lasercube.Discover(time.Second)
+		if err != nil {
+			log.Fatalf("Network error: %v", err)
+		}
+		if len(cubes) == 0 {
+			log.Fatal("No LaserCubes found")
+		}
+		for _, c := range cubes {
+			log.Printf("Found %v\n", c)
+		}
+		*host = cubes[0].Addr.IP.String()
+	}
+
+	out, err := lasercube.Dial(*host)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer out.Close()
+	log.Printf("Connected to %v, battery %v%%\n", out.Info, out.Info.Battery)
+
+	if err := etherdream.PlayStream(context.Background(), out, g.Stream); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -46,7 +46,9 @@ func main() {
 	log.Printf("Initialized:  %v\n\n", dac.LastStatus)
 	log.Printf("Firmware String: %v\n\n", dac.FirmwareString)
 
-	dac.Play(pointStream)
+	if err := dac.Play(pointStream); err != nil {
+		log.Fatal(err)
+	}
 }
 
 var max = flag.Int("speed", 500, "Speed to run the oscillation (1-20000)")
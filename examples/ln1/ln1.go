@@ -44,7 +44,9 @@ func main() {
 	}
 	defer dac.Close()
 
-	dac.Play(pointStream)
+	if err := dac.Play(pointStream); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func pointStream(w io.WriteCloser) {
@@ -75,21 +77,18 @@ func pointStream(w io.WriteCloser) {
 
 		// compute 2D paths that depict the 3D scene
 		paths := scene.Render(eye, center, up, width, height, fovy, znear, zfar, step)
-		paths.Optimize()
-
-		lp := len(paths)
-		for iX := 0; iX < lp; iX++ {
-			p := paths[iX]
-			p2 := paths[0]
-			if iX+1 < lp {
-				p2 = paths[iX+1]
-			}
-
-			etherdream.DrawPath(w, p, c, speed)
-			if p2[0].Distance(p[1]) > 0 {
-				etherdream.BlankPath(w, ln.Path{p[1], p2[0]})
-			}
+
+		// order the paths to cut down on blanking
+		cps := make(etherdream.ColorPaths, len(paths))
+		for iX, p := range paths {
+			cps[iX] = etherdream.ColorPath{Path: p, Color: c}
 		}
+		cps, report := etherdream.OptimizePaths(cps, etherdream.OptimizeOptions{DrawSpeed: speed})
+		if frame == 0 {
+			log.Printf("Optimized paths\n%v", report)
+		}
+
+		etherdream.DrawPaths(w, cps, speed)
 
 		frame++
 	}
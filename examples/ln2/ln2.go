@@ -48,7 +48,9 @@ func main() {
 	}
 	defer dac.Close()
 
-	dac.Play(pointStream)
+	if err := dac.Play(pointStream); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func cube(x, y, z float64) ln.Shape {
@@ -19,16 +19,29 @@
 package main
 
 import (
+	"flag"
 	"io"
 	"log"
+	"path/filepath"
 
 	"image/color"
 
 	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/etherdream/preview"
 	"github.com/tgreiser/ln/ln"
 )
 
+var previewDir = flag.String("preview", "", "Write ideal and simulated galvo PNGs to this directory instead of playing.")
+var bandwidth = flag.Float64("bandwidth", 1500, "Simulated galvo bandwidth in Hz.")
+var damping = flag.Float64("damping", 0.6, "Simulated galvo damping ratio.")
+
 func main() {
+	flag.Parse()
+	if *previewDir != "" {
+		writePreviews(*previewDir)
+		return
+	}
+
 	log.Printf("Listening...\n")
 	addr, _, err := etherdream.FindFirstDAC()
 	if err != nil {
@@ -43,7 +56,9 @@ func main() {
 	}
 	defer dac.Close()
 
-	dac.Play(pointStream)
+	if err := dac.Play(pointStream); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func line(x, y, z, x2, y2, z2 float64) ln.Path {
@@ -91,3 +106,17 @@ func pointStream(w io.WriteCloser) {
 		frame++
 	}
 }
+
+// writePreviews draws a few frames as the points ask and as the
+// simulated mirrors follow them, to compare side by side
+func writePreviews(dir string) {
+	opts := preview.Options{ShowBlanking: true}
+	if _, err := preview.SaveStream(pointStream, 3, filepath.Join(dir, "ideal%02d.png"), opts); err != nil {
+		log.Fatal(err)
+	}
+	opts.Galvo = preview.NewGalvo(*bandwidth, *damping)
+	if _, err := preview.SaveStream(pointStream, 3, filepath.Join(dir, "galvo%02d.png"), opts); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote previews to %v\n", dir)
+}
@@ -0,0 +1,82 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/etherdream/ilda"
+	"github.com/tgreiser/etherdream/preview"
+	"github.com/tgreiser/etherdream/svg"
+)
+
+var file = flag.String("file", "", "ILDA (.ild) or SVG file to preview.")
+var out = flag.String("out", "frame%04d.png", "PNG file name pattern for the frame number.")
+var frames = flag.Int("frames", 1, "Number of frames to write.")
+var size = flag.Int("size", 512, "Width and height of the images.")
+var beam = flag.Float64("beam", 1.5, "Beam width in pixels.")
+var glow = flag.Float64("glow", 4, "Glow radius in pixels, 0 for none.")
+var blank = flag.Bool("blank", false, "Draw the blanked moves too.")
+
+func main() {
+	flag.Parse()
+
+	opts := preview.Options{
+		Width:        *size,
+		Height:       *size,
+		BeamWidth:    *beam,
+		Glow:         *glow,
+		ShowBlanking: *blank,
+	}
+
+	var n int
+	var err error
+	switch strings.ToLower(filepath.Ext(*file)) {
+	case ".ild":
+		var fs []ilda.Frame
+		if fs, err = ilda.ReadFile(*file); err != nil {
+			log.Fatal(err)
+		}
+		n, err = preview.SaveFrames(context.Background(), ilda.Source(fs, 30, false), *frames, *out, opts)
+	case ".svg":
+		var paths etherdream.ColorPaths
+		if paths, err = svg.Load(*file, svg.Options{}); err != nil {
+			log.Fatal(err)
+		}
+		n, err = preview.SaveStream(func(w io.WriteCloser) {
+			defer w.Close()
+			for {
+				n, last := etherdream.DrawPaths(w, paths, 0.0)
+				if etherdream.NextFrame(w, n, last) < 0 {
+					return
+				}
+			}
+		}, *frames, *out, opts)
+	default:
+		log.Fatalf("Can't preview %v, use an .ild or .svg file", *file)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote %v frames\n", n)
+}
@@ -19,15 +19,10 @@
 package main
 
 import (
-	"io"
 	"log"
 
-	"math"
-
-	"image/color"
-
 	"github.com/tgreiser/etherdream"
-	"github.com/tgreiser/ln/ln"
+	"github.com/tgreiser/etherdream/generators"
 )
 
 func main() {
@@ -45,55 +40,7 @@ func main() {
 	}
 	defer dac.Close()
 
-	dac.Play(pointStream)
-}
-
-func pointStream(w io.WriteCloser) {
-	defer w.Close()
-
-	c := color.RGBA{0x88, 0x00, 0x55, 0xFF}
-	maxrad := 22600
-	rad := int(maxrad / 100)
-	var spiralgrowth float64 = 14
-
-	for {
-		var j float64
-
-		to := etherdream.FramePoints() - *etherdream.BlankCount
-		for _, i := range xrange(0, to, 1) {
-			f := float64(i) / 1000.0 * 2.0 * math.Pi * spiralgrowth
-			j = f
-			x := int(j * math.Cos(f) * float64(rad))
-			y := int(j * math.Sin(f) * float64(rad))
-			w.Write(etherdream.NewPoint(x, y, c).Encode())
-		}
-
-		// blank and return to origin
-		f := 1000.0 / 1000.0 * 2.0 * math.Pi * spiralgrowth
-		j = f
-		x := j * math.Cos(f) * float64(rad)
-		y := j * math.Sin(f) * float64(rad)
-		p := ln.Path{ln.Vector{x, y, 0}, ln.Vector{0, 0, 0}}
-		pt := etherdream.BlankPath(w, p)
-
-		_ = etherdream.NextFrame(w, etherdream.FramePoints(), *pt)
-	}
-}
-
-func xrange(min, max, step int) []int {
-	rng := max - min
-	ret := make([]int, rng/step+1)
-	iY := 0
-	for iX := min; rlogic(min, max, iX); iX += step {
-		ret[iY] = iX
-		iY++
-	}
-	return ret
-}
-
-func rlogic(min, max, iX int) bool {
-	if min < max {
-		return iX <= max
+	if err := dac.Play(generators.Spiral); err != nil {
+		log.Fatal(err)
 	}
-	return iX >= max
 }
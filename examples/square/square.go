@@ -19,13 +19,10 @@
 package main
 
 import (
-	"io"
 	"log"
-	"math"
-
-	"image/color"
 
 	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/etherdream/generators"
 )
 
 func main() {
@@ -46,63 +43,7 @@ func main() {
 	log.Printf("Initialized:  %v\n\n", dac.LastStatus)
 	log.Printf("Firmware String: %v\n\n", dac.FirmwareString)
 
-	dac.Play(squarePointStream)
-}
-
-func squareFrame(w io.WriteCloser, pmax, pstep int) *etherdream.Point {
-	for _, x := range xrange(-pmax, pmax, pstep) {
-		w.Write(etherdream.NewPoint(x, pmax, color.RGBA{0xff, 0x00, 0x00, 0xff}).Encode())
-	}
-	for _, y := range xrange(pmax, -pmax, -pstep) {
-		w.Write(etherdream.NewPoint(pmax, y, color.RGBA{0x00, 0xff, 0x00, 0xff}).Encode())
-	}
-	for _, x := range xrange(pmax, -pmax, -pstep) {
-		w.Write(etherdream.NewPoint(x, -pmax, color.RGBA{0x00, 0x00, 0xff, 0xff}).Encode())
-	}
-	var pt *etherdream.Point
-	for _, y := range xrange(-pmax, pmax, pstep) {
-		pt = etherdream.NewPoint(-pmax, y, color.RGBA{0xff, 0xff, 0xff, 0xff})
-		w.Write(pt.Encode())
-	}
-	return pt
-}
-
-func squarePointStream(w io.WriteCloser) {
-	defer w.Close()
-	pmax := 5600
-	pstep := 112
-	pct := pmax / pstep * 4
-	for {
-		var pt *etherdream.Point
-		ct := pct
-		times := int(math.Floor(float64(etherdream.FramePoints() / ct)))
-		ct = 0
-
-		// This approach gives flicker free draw, when
-		// repeated 4x
-		for i := 0; i < times; i++ {
-			pt = squareFrame(w, pmax, pstep)
-			ct += pct
-		}
-
-		etherdream.NextFrame(w, ct, *pt)
-	}
-}
-
-func xrange(min, max, step int) []int {
-	rng := max - min
-	ret := make([]int, rng/step+1)
-	iY := 0
-	for iX := min; rlogic(min, max, iX); iX += step {
-		ret[iY] = iX
-		iY++
-	}
-	return ret
-}
-
-func rlogic(min, max, iX int) bool {
-	if min < max {
-		return iX <= max
+	if err := dac.Play(generators.Square); err != nil {
+		log.Fatal(err)
 	}
-	return iX >= max
 }
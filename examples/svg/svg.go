@@ -0,0 +1,83 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/etherdream/svg"
+)
+
+var file = flag.String("file", "", "SVG file to draw.")
+var tolerance = flag.Float64("tolerance", 40, "How far flattened curves may stray, in DAC units.")
+var extent = flag.Float64("extent", 20000, "Fit the drawing into +/- this many DAC units.")
+var fps = flag.Int("fps", 0, "Fit each frame to the point budget at this frame rate instead of using -draw-speed.")
+
+func main() {
+	flag.Parse()
+
+	paths, err := svg.Load(*file, svg.Options{Tolerance: *tolerance, Extent: *extent})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Loaded %v paths from %v\n", len(paths), *file)
+
+	log.Printf("Listening...\n")
+	addr, _, err := etherdream.FindFirstDAC()
+	if err != nil {
+		log.Fatalf("Network error: %v", err)
+	}
+
+	log.Printf("Found DAC at %v\n", addr)
+
+	dac, err := etherdream.NewDAC(addr.IP.String())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dac.Close()
+
+	if *fps > 0 {
+		f, err := etherdream.FitFrame(paths, etherdream.FitOptions{FPS: *fps})
+		if err != nil {
+			log.Printf("%v", err)
+		}
+		log.Printf("Fitted %v points per frame\n", len(f.Points))
+		frames := etherdream.FrameSourceFunc(func(ctx context.Context) (etherdream.Frame, error) {
+			return f, ctx.Err()
+		})
+		if err := dac.PlayFrames(context.Background(), frames); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := dac.Play(func(w io.WriteCloser) {
+		defer w.Close()
+		for {
+			n, last := etherdream.DrawPaths(w, paths, 0.0)
+			if etherdream.NextFrame(w, n, last) < 0 {
+				return
+			}
+		}
+	}); err != nil {
+		log.Fatal(err)
+	}
+}
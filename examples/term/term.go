@@ -0,0 +1,76 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/tgreiser/etherdream"
+	_ "github.com/tgreiser/etherdream/generators"
+	"github.com/tgreiser/etherdream/preview"
+)
+
+var gen = flag.String("gen", "circle", "Name of the generator to run, see laserexport -list.")
+var cols = flag.Int("cols", 80, "Width of the terminal in characters.")
+var rows = flag.Int("rows", 24, "Height of the terminal in lines.")
+var fps = flag.Int("fps", 10, "Most redraws per second.")
+var useDAC = flag.Bool("dac", false, "Play to the first DAC found as well as the terminal.")
+
+func main() {
+	flag.Parse()
+	g, ok := etherdream.LookupGenerator(*gen)
+	if !ok {
+		log.Fatalf("No generator named %q", *gen)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	t := preview.NewTerminal(os.Stdout)
+	t.Cols, t.Rows, t.MaxFPS = *cols, *rows, *fps
+	defer t.Close()
+
+	src := etherdream.NewStreamSource(g.Stream)
+	defer src.Close()
+
+	if !*useDAC {
+		if err := t.Play(ctx, src, 0); err != nil && err != context.Canceled {
+			log.Print(err)
+		}
+		return
+	}
+
+	addr, _, err := etherdream.FindFirstDAC()
+	if err != nil {
+		log.Fatalf("Network error: %v", err)
+	}
+	dac, err := etherdream.NewDAC(addr.IP.String())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dac.Close()
+	t.DAC = dac
+
+	if err := dac.PlayFrames(ctx, t.Tee(src)); err != nil && err != context.Canceled {
+		log.Print(err)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"image/color"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/etherdream/text"
+)
+
+var msg = flag.String("text", "HELLO\nLASER", "Text to draw, \\n starts a new line.")
+var size = flag.Float64("size", 4000, "Height of a capital in DAC units.")
+var marquee = flag.Bool("marquee", false, "Scroll the text through a window instead.")
+var speed = flag.Float64("speed", 300, "Marquee speed in DAC units per frame.")
+
+// rainbow colors each letter in turn
+var rainbow = []color.Color{
+	color.RGBA{0xff, 0x00, 0x00, 0xff},
+	color.RGBA{0xff, 0xff, 0x00, 0xff},
+	color.RGBA{0x00, 0xff, 0x00, 0xff},
+	color.RGBA{0x00, 0xff, 0xff, 0xff},
+	color.RGBA{0x00, 0x00, 0xff, 0xff},
+	color.RGBA{0xff, 0x00, 0xff, 0xff},
+}
+
+func main() {
+	flag.Parse()
+	str := strings.ReplaceAll(*msg, `\n`, "\n")
+
+	style := text.Style{
+		Size:  *size,
+		Align: text.AlignCenter,
+		Colors: func(i int, r rune) color.Color {
+			return rainbow[i%len(rainbow)]
+		},
+	}
+
+	log.Printf("Listening...\n")
+	addr, _, err := etherdream.FindFirstDAC()
+	if err != nil {
+		log.Fatalf("Network error: %v", err)
+	}
+
+	log.Printf("Found DAC at %v\n", addr)
+
+	dac, err := etherdream.NewDAC(addr.IP.String())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dac.Close()
+
+	if *marquee {
+		m := text.NewMarquee(str, style, 40000)
+		m.Speed = *speed
+		if err := dac.PlayFrames(context.Background(), m); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// center the block of text on the origin
+	_, h := style.Measure(str)
+	paths := style.Render(str, 0, h/2-*size)
+	if err := dac.Play(func(w io.WriteCloser) {
+		defer w.Close()
+		for {
+			n, last := etherdream.DrawPaths(w, paths, 0.0)
+			if etherdream.NextFrame(w, n, last) < 0 {
+				return
+			}
+		}
+	}); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,75 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"flag"
+	"image/color"
+	"io"
+	"log"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/etherdream/toolpath"
+)
+
+var file = flag.String("file", "", "HPGL (.plt, .hpgl) or G-code file to draw.")
+var scale = flag.Float64("scale", 100, "DAC units per millimetre.")
+var originX = flag.Float64("origin-x", 0, "X position in mm to place at the center.")
+var originY = flag.Float64("origin-y", 0, "Y position in mm to place at the center.")
+var tolerance = flag.Float64("tolerance", 40, "How far flattened arcs may stray, in DAC units.")
+
+func main() {
+	flag.Parse()
+
+	paths, err := toolpath.Load(*file, toolpath.Options{
+		Scale:     *scale,
+		OriginX:   *originX,
+		OriginY:   *originY,
+		Color:     color.RGBA{0x00, 0xff, 0x00, 0xff},
+		Tolerance: *tolerance,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Loaded %v paths from %v\n", len(paths), *file)
+
+	log.Printf("Listening...\n")
+	addr, _, err := etherdream.FindFirstDAC()
+	if err != nil {
+		log.Fatalf("Network error: %v", err)
+	}
+
+	log.Printf("Found DAC at %v\n", addr)
+
+	dac, err := etherdream.NewDAC(addr.IP.String())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dac.Close()
+
+	if err := dac.Play(func(w io.WriteCloser) {
+		defer w.Close()
+		for {
+			n, last := etherdream.DrawPaths(w, paths, 0.0)
+			if etherdream.NextFrame(w, n, last) < 0 {
+				return
+			}
+		}
+	}); err != nil {
+		log.Fatal(err)
+	}
+}
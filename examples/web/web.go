@@ -0,0 +1,74 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/tgreiser/etherdream"
+	_ "github.com/tgreiser/etherdream/generators"
+	"github.com/tgreiser/etherdream/preview"
+)
+
+var gen = flag.String("gen", "circle", "Name of the generator to run, see laserexport -list.")
+var addr = flag.String("addr", "localhost:8080", "Address to serve the preview page on.")
+var useDAC = flag.Bool("dac", false, "Play to the first DAC found as well as the browser.")
+
+func main() {
+	flag.Parse()
+	g, ok := etherdream.LookupGenerator(*gen)
+	if !ok {
+		log.Fatalf("No generator named %q", *gen)
+	}
+
+	s := preview.NewServer()
+	go func() {
+		log.Fatal(http.ListenAndServe(*addr, s))
+	}()
+	log.Printf("Preview at http://%v/\n", *addr)
+
+	src := etherdream.NewStreamSource(g.Stream)
+	defer src.Close()
+	ctx := context.Background()
+
+	if !*useDAC {
+		if err := s.Play(ctx, src, 0); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	log.Printf("Listening...\n")
+	dacAddr, _, err := etherdream.FindFirstDAC()
+	if err != nil {
+		log.Fatalf("Network error: %v", err)
+	}
+	log.Printf("Found DAC at %v\n", dacAddr)
+
+	dac, err := etherdream.NewDAC(dacAddr.IP.String())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dac.Close()
+
+	if err := dac.PlayFrames(ctx, s.Tee(src)); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"fmt"
+)
+
+// FitOptions control FitFrame
+type FitOptions struct {
+	// FPS is the target frame rate, 30 when 0
+	FPS int
+	// ScanRate in points per second, the -scan-rate flag when 0
+	ScanRate int
+	// Profile sets the corner and end dwell and the blanking, the shape
+	// of the spacing along each run is kept but scaled to fit.
+	// DefaultProfile when nil.
+	Profile *Profile
+}
+
+// FitError is returned when the paths need more points than one frame
+// has room for, even at the coarsest spacing
+type FitError struct {
+	Needed int
+	Budget int
+}
+
+func (e *FitError) Error() string {
+	return fmt.Sprintf("frame needs %v points but the budget is %v, draw fewer paths or lower the frame rate", e.Needed, e.Budget)
+}
+
+// FrameBudget is the number of points in one frame at fps
+func FrameBudget(scanRate, fps int) int {
+	if fps <= 0 {
+		return scanRate
+	}
+	return scanRate / fps
+}
+
+// FitFrame renders paths to a frame of exactly ScanRate / FPS points.
+// Dwell at corners and ends and the blanking moves come first, then
+// the spacing along the lit runs is made as fine as the budget allows.
+// Spare points wait blanked at the end of the frame.
+//
+// When the paths can't fit, the frame is still rendered at the coarsest
+// spacing and returned along with a *FitError. It plays, just slower
+// than the target frame rate.
+func FitFrame(paths ColorPaths, opts FitOptions) (Frame, error) {
+	if opts.FPS == 0 {
+		opts.FPS = frameRate
+	}
+	if opts.ScanRate == 0 {
+		opts.ScanRate = *ScanRate
+	}
+	pr := DefaultProfile()
+	if opts.Profile != nil {
+		pr = *opts.Profile
+	}
+	budget := FrameBudget(opts.ScanRate, opts.FPS)
+
+	// scale the spacing up until the frame fits, the points only
+	// go down as k goes up
+	render := func(k float64) []Point {
+		sp := pr
+		sp.MinStep *= k
+		sp.MaxStep *= k
+		sp.Accel *= k
+		return sp.framePoints(paths)
+	}
+	lo, hi := 1e-3, 1.0
+	pts := render(hi)
+	for len(pts) > budget && hi < 1e6 {
+		lo, hi = hi, hi*4
+		pts = render(hi)
+	}
+	if len(pts) > budget {
+		return Frame{Points: pts}, &FitError{Needed: len(pts), Budget: budget}
+	}
+	// the finest spacing that still fits
+	for iX := 0; iX < 30 && hi-lo > hi*1e-3; iX++ {
+		mid := (lo + hi) / 2
+		if p := render(mid); len(p) <= budget {
+			hi, pts = mid, p
+		} else {
+			lo = mid
+		}
+	}
+
+	var last Point
+	if len(pts) > 0 {
+		last = pts[len(pts)-1]
+	}
+	for len(pts) < budget {
+		pts = append(pts, *NewPoint(int(last.X), int(last.Y), BlankColor))
+	}
+	return Frame{Points: pts}, nil
+}
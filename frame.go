@@ -0,0 +1,246 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Frame is one complete image for the laser. The points are played
+// back in order, then the next frame follows.
+type Frame struct {
+	Points []Point
+	// Rate is the point rate to play the frame at, 0 keeps the rate
+	// playing. A DAC queues the change so it lands on the first point,
+	// IDN and LaserCube outputs change as the frame is sent and a paced
+	// NullOutput paces by it. Files, images and previews have no rate
+	// and ignore it.
+	Rate int
+}
+
+// Encode the frame to the 18 byte per point wire format
+func (f Frame) Encode() []byte {
+	enc := make([]byte, len(f.Points)*int(PointSize))
+	for iX, p := range f.Points {
+		p.put(enc[iX*int(PointSize):])
+	}
+	return enc
+}
+
+// FrameSource is the structured alternative to a PointStream. NextFrame
+// blocks until a frame is ready. Return io.EOF when there are no more
+// frames, any other error stops playback and is handed back to the caller.
+type FrameSource interface {
+	NextFrame(ctx context.Context) (Frame, error)
+}
+
+// FrameSourceFunc lets an ordinary function act as a FrameSource
+type FrameSourceFunc func(ctx context.Context) (Frame, error)
+
+// NextFrame calls f(ctx)
+func (f FrameSourceFunc) NextFrame(ctx context.Context) (Frame, error) {
+	return f(ctx)
+}
+
+// ChannelSource reads frames from a channel, closing the channel ends
+// playback.
+func ChannelSource(frames <-chan Frame) FrameSource {
+	return FrameSourceFunc(func(ctx context.Context) (Frame, error) {
+		select {
+		case f, ok := <-frames:
+			if !ok {
+				return Frame{}, io.EOF
+			}
+			return f, nil
+		case <-ctx.Done():
+			return Frame{}, ctx.Err()
+		}
+	})
+}
+
+// ReaderSource reads encoded points from r, a PointStream recorded to a
+// file for example, and splits them into frames of framePoints points.
+// The stream has no frame boundaries of its own.
+func ReaderSource(r io.Reader, framePoints int) FrameSource {
+	buf := make([]byte, framePoints*int(PointSize))
+	return FrameSourceFunc(func(ctx context.Context) (Frame, error) {
+		if err := ctx.Err(); err != nil {
+			return Frame{}, err
+		}
+		n, err := io.ReadFull(r, buf)
+		if err == io.ErrUnexpectedEOF {
+			err = nil
+		}
+		n /= int(PointSize)
+		if n == 0 {
+			return Frame{}, io.EOF
+		}
+		if err != nil {
+			return Frame{}, err
+		}
+		f := Frame{Points: make([]Point, n)}
+		for iX := range f.Points {
+			f.Points[iX] = DecodePoint(buf[iX*int(PointSize):])
+		}
+		return f, nil
+	})
+}
+
+// FrameWriter is a WriteCloser that knows about frame boundaries.
+// NextFrame calls EndFrame when the writer it is given implements it.
+type FrameWriter interface {
+	io.WriteCloser
+	EndFrame() error
+}
+
+// StreamSource adapts a PointStream to a FrameSource. The stream runs in
+// its own goroutine and the encoded points are split into frames at each
+// NextFrame call. Streams that never call NextFrame are split every
+// FramePoints() points.
+type StreamSource struct {
+	stream PointStream
+	frames chan Frame
+	done   chan struct{}
+	start  sync.Once
+	stop   sync.Once
+	w      *streamWriter
+}
+
+// NewStreamSource wraps stream, the stream is started on the first call
+// to NextFrame.
+func NewStreamSource(stream PointStream) *StreamSource {
+	s := &StreamSource{
+		stream: stream,
+		frames: make(chan Frame),
+		done:   make(chan struct{}),
+	}
+	s.w = &streamWriter{frames: s.frames, done: s.done}
+	return s
+}
+
+// NextFrame waits for the stream to finish a frame
+func (s *StreamSource) NextFrame(ctx context.Context) (Frame, error) {
+	s.start.Do(func() {
+		go s.stream(s.w)
+	})
+	select {
+	case f, ok := <-s.frames:
+		if !ok {
+			return Frame{}, io.EOF
+		}
+		return f, nil
+	case <-ctx.Done():
+		return Frame{}, ctx.Err()
+	}
+}
+
+// Close releases the stream goroutine, further writes to the stream
+// fail with io.ErrClosedPipe. A stream ends at its next call to
+// NextFrame, one that never calls it should stop on the write error.
+func (s *StreamSource) Close() error {
+	s.stop.Do(func() {
+		close(s.done)
+	})
+	return nil
+}
+
+// streamWriter collects encoded points from a PointStream into frames
+type streamWriter struct {
+	frames chan Frame
+	done   chan struct{}
+	mu     sync.Mutex
+	part   []byte
+	cur    []Point
+	framed bool
+	closed bool
+}
+
+func (w *streamWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.stopped(); err != nil {
+		return 0, err
+	}
+
+	w.part = append(w.part, b...)
+	ps := int(PointSize)
+	n := len(w.part) / ps
+	for iX := 0; iX < n; iX++ {
+		w.cur = append(w.cur, DecodePoint(w.part[iX*ps:]))
+		if !w.framed && len(w.cur) >= FramePoints() {
+			if err := w.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	w.part = append(w.part[:0], w.part[n*ps:]...)
+	return len(b), nil
+}
+
+// EndFrame sends the points written since the last boundary as a frame
+func (w *streamWriter) EndFrame() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.stopped(); err != nil {
+		return err
+	}
+	w.framed = true
+	return w.flush()
+}
+
+// Close flushes any remaining points and ends the stream
+func (w *streamWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	err := w.flush()
+	w.closed = true
+	close(w.frames)
+	return err
+}
+
+// stopped is io.ErrClosedPipe once the stream or the source is closed,
+// so a stream writing between frames sees it and returns
+func (w *streamWriter) stopped() error {
+	if w.closed {
+		return io.ErrClosedPipe
+	}
+	select {
+	case <-w.done:
+		return io.ErrClosedPipe
+	default:
+		return nil
+	}
+}
+
+func (w *streamWriter) flush() error {
+	if len(w.cur) == 0 {
+		return nil
+	}
+	f := Frame{Points: w.cur}
+	w.cur = nil
+	select {
+	case w.frames <- f:
+		return nil
+	case <-w.done:
+		return io.ErrClosedPipe
+	}
+}
@@ -0,0 +1,107 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"context"
+	"image/color"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestStreamSourceEndFrame(t *testing.T) {
+	src := NewStreamSource(func(w io.WriteCloser) {
+		defer w.Close()
+		for _, n := range []int{3, 5} {
+			for iX := 0; iX < n; iX++ {
+				w.Write(NewPoint(iX, n, color.White).Encode())
+			}
+			w.(FrameWriter).EndFrame()
+		}
+	})
+	defer src.Close()
+
+	ctx := context.Background()
+	for _, n := range []int{3, 5} {
+		f, err := src.NextFrame(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Points) != n || f.Points[0].Y != int16(n) {
+			t.Errorf("frame of %v points at y %v, want %v", len(f.Points), f.Points[0].Y, n)
+		}
+	}
+	if _, err := src.NextFrame(ctx); err != io.EOF {
+		t.Errorf("after the stream: err = %v, want io.EOF", err)
+	}
+}
+
+func TestStreamSourceSplits(t *testing.T) {
+	n := FramePoints()
+	src := NewStreamSource(func(w io.WriteCloser) {
+		defer w.Close()
+		// points written a byte at a time still decode
+		b := NewPoint(1, 2, color.White).Encode()
+		for iX := 0; iX < 2*n+7; iX++ {
+			for _, c := range b {
+				w.Write([]byte{c})
+			}
+		}
+	})
+	defer src.Close()
+
+	ctx := context.Background()
+	for _, want := range []int{n, n, 7} {
+		f, err := src.NextFrame(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Points) != want {
+			t.Errorf("frame of %v points, want %v", len(f.Points), want)
+		}
+	}
+}
+
+func TestStreamSourceClose(t *testing.T) {
+	ended := make(chan int)
+	src := NewStreamSource(func(w io.WriteCloser) {
+		defer w.Close()
+		for {
+			if fc := NextFrame(w, 0, Point{}); fc < 0 {
+				ended <- fc
+				return
+			}
+		}
+	})
+	if _, err := src.NextFrame(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	src.Close()
+
+	select {
+	case fc := <-ended:
+		if fc != -1 {
+			t.Errorf("NextFrame = %v, want -1", fc)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the stream is still running after Close")
+	}
+	if _, err := src.w.Write(NewPoint(0, 0, color.White).Encode()); err != io.ErrClosedPipe {
+		t.Errorf("write after Close: err = %v, want io.ErrClosedPipe", err)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Generator is a PointStream registered by name, so tools can run it
+// without knowing where it comes from
+type Generator struct {
+	Name        string
+	Description string
+	Stream      PointStream
+}
+
+var (
+	generatorsMu sync.RWMutex
+	generators   = map[string]Generator{}
+)
+
+// RegisterGenerator makes a stream available by name, usually from a
+// package's init. It panics if the name is already taken.
+func RegisterGenerator(name, description string, stream PointStream) {
+	generatorsMu.Lock()
+	defer generatorsMu.Unlock()
+	if _, dup := generators[name]; dup {
+		panic(fmt.Sprintf("etherdream: generator %q registered twice", name))
+	}
+	generators[name] = Generator{Name: name, Description: description, Stream: stream}
+}
+
+// LookupGenerator finds a registered generator by name
+func LookupGenerator(name string) (Generator, bool) {
+	generatorsMu.RLock()
+	defer generatorsMu.RUnlock()
+	g, ok := generators[name]
+	return g, ok
+}
+
+// Generators lists the registered generators sorted by name
+func Generators() []Generator {
+	generatorsMu.RLock()
+	defer generatorsMu.RUnlock()
+	ret := make([]Generator, 0, len(generators))
+	for _, g := range generators {
+		ret = append(ret, g)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
@@ -0,0 +1,221 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package generators holds the example patterns as PointStreams and
+// registers them with etherdream, so tools like laserexport can run them
+// by name. Import it for its side effects:
+//
+//	import _ "github.com/tgreiser/etherdream/generators"
+//
+// or play one directly with dac.Play(generators.Circle).
+package generators
+
+import (
+	"context"
+	"image/color"
+	"io"
+	"math"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/etherdream/text"
+	"github.com/tgreiser/ln/ln"
+)
+
+func init() {
+	etherdream.RegisterGenerator("circle", "a circle that shrinks and grows", Circle)
+	etherdream.RegisterGenerator("spiral", "a spiral out from the center", Spiral)
+	etherdream.RegisterGenerator("square", "a square with a different color on each side", Square)
+	etherdream.RegisterGenerator("lissajous", "a drifting Lissajous figure", Lissajous)
+	etherdream.RegisterGenerator("cube", "a rotating wireframe cube", Cube)
+	etherdream.RegisterGenerator("marquee", "scrolling text", Marquee)
+}
+
+// Circle draws a circle that shrinks to a point and grows back
+func Circle(w io.WriteCloser) {
+	defer w.Close()
+	// Don't use a low # of steps, 30 and below can damage galvos
+	// We'll use the number of points in a frame for optimal sampling
+	pstep := etherdream.FramePoints()
+	c := color.RGBA{0x66, 0x33, 0x00, 0xFF}
+	maxrad := 10260
+	rad := maxrad
+	grow := false
+	for {
+		if rad <= 1 {
+			grow = true
+		} else if rad >= maxrad {
+			grow = false
+		}
+		if grow {
+			rad += 100
+		} else {
+			rad -= 100
+		}
+		var pt *etherdream.Point
+		for iX := 0; iX < pstep; iX++ {
+			f := float64(iX) / float64(pstep) * 2.0 * math.Pi
+			pt = etherdream.NewPoint(int(math.Cos(f)*float64(rad)), int(math.Sin(f)*float64(rad)), c)
+			if _, err := w.Write(pt.Encode()); err != nil {
+				return
+			}
+		}
+		if etherdream.NextFrame(w, pstep, *pt) < 0 {
+			return
+		}
+	}
+}
+
+// Spiral draws a spiral out from the center, then blanks back to it
+func Spiral(w io.WriteCloser) {
+	defer w.Close()
+	c := color.RGBA{0x88, 0x00, 0x55, 0xFF}
+	rad := 226.0
+	growth := 14.0
+	for {
+		to := etherdream.FramePoints() - *etherdream.BlankCount
+		for iX := 0; iX < to; iX++ {
+			f := float64(iX) / 1000.0 * 2.0 * math.Pi * growth
+			pt := etherdream.NewPoint(int(f*math.Cos(f)*rad), int(f*math.Sin(f)*rad), c)
+			if _, err := w.Write(pt.Encode()); err != nil {
+				return
+			}
+		}
+		f := float64(to) / 1000.0 * 2.0 * math.Pi * growth
+		from := ln.Vector{X: f * math.Cos(f) * rad, Y: f * math.Sin(f) * rad}
+		pt := etherdream.BlankPath(w, ln.Path{from, {}})
+		if etherdream.NextFrame(w, to+*etherdream.BlankCount, *pt) < 0 {
+			return
+		}
+	}
+}
+
+// Square draws a square with a different color on each side
+func Square(w io.WriteCloser) {
+	defer w.Close()
+	pmax, pstep := 5600, 112
+	sides := []struct {
+		x0, y0, dx, dy int
+		c              color.Color
+	}{
+		{-pmax, pmax, pstep, 0, color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		{pmax, pmax, 0, -pstep, color.RGBA{0x00, 0xff, 0x00, 0xff}},
+		{pmax, -pmax, -pstep, 0, color.RGBA{0x00, 0x00, 0xff, 0xff}},
+		{-pmax, -pmax, 0, pstep, color.RGBA{0xff, 0xff, 0xff, 0xff}},
+	}
+	n := 2 * pmax / pstep
+	for {
+		var pt *etherdream.Point
+		ct := 0
+		// repeating the square to fill the frame gives flicker free
+		// draw
+		for ct+4*n <= etherdream.FramePoints() {
+			for _, s := range sides {
+				for iX := 0; iX < n; iX++ {
+					pt = etherdream.NewPoint(s.x0+s.dx*iX, s.y0+s.dy*iX, s.c)
+					if _, err := w.Write(pt.Encode()); err != nil {
+						return
+					}
+				}
+			}
+			ct += 4 * n
+		}
+		if etherdream.NextFrame(w, ct, *pt) < 0 {
+			return
+		}
+	}
+}
+
+// Lissajous draws a 3:2 Lissajous figure that slowly changes phase
+func Lissajous(w io.WriteCloser) {
+	defer w.Close()
+	n := etherdream.FramePoints() - *etherdream.BlankCount
+	c := color.RGBA{0x00, 0x88, 0x66, 0xFF}
+	amp := 12000.0
+	phase := 0.0
+	for {
+		var pt *etherdream.Point
+		for iX := 0; iX <= n; iX++ {
+			t := float64(iX) / float64(n) * 2 * math.Pi
+			pt = etherdream.NewPoint(int(amp*math.Sin(3*t+phase)), int(amp*math.Sin(2*t)), c)
+			if _, err := w.Write(pt.Encode()); err != nil {
+				return
+			}
+		}
+		phase += 0.02
+		start := ln.Vector{X: amp * math.Sin(phase)}
+		pt = etherdream.BlankPath(w, ln.Path{pt.ToVector(), start})
+		if etherdream.NextFrame(w, n+1+*etherdream.BlankCount, *pt) < 0 {
+			return
+		}
+	}
+}
+
+// Cube draws a wireframe cube turning in front of the camera
+func Cube(w io.WriteCloser) {
+	defer w.Close()
+	scene := ln.Scene{}
+	scene.Add(ln.NewCube(ln.Vector{X: -1, Y: -1, Z: -1}, ln.Vector{X: 1, Y: 1, Z: 1}))
+	c := color.RGBA{0x88, 0x00, 0x00, 0xFF}
+	pr := etherdream.DefaultProfile()
+	for frame := 0; ; frame++ {
+		a := float64(frame) * 0.02
+		eye := ln.Vector{X: 4 * math.Cos(a), Y: 4 * math.Sin(a), Z: 2}
+		paths := scene.Render(eye, ln.Vector{}, ln.Vector{Z: 1}, 10240, 10240, 50, 0.1, 10, 0.01)
+		cps := make(etherdream.ColorPaths, len(paths))
+		for iX, p := range paths {
+			// ln renders from the corner, the DAC is centered on 0, 0
+			for iY := range p {
+				p[iY] = p[iY].SubScalar(5120)
+				p[iY].Z = 0
+			}
+			cps[iX] = etherdream.ColorPath{Path: p, Color: c}
+		}
+		cps, _ = etherdream.OptimizePaths(cps, etherdream.OptimizeOptions{})
+		f, _ := etherdream.FitFrame(cps, etherdream.FitOptions{Profile: &pr})
+		for _, pt := range f.Points {
+			if _, err := w.Write(pt.Encode()); err != nil {
+				return
+			}
+		}
+		var last etherdream.Point
+		if len(f.Points) > 0 {
+			last = f.Points[len(f.Points)-1]
+		}
+		if etherdream.NextFrame(w, len(f.Points), last) < 0 {
+			return
+		}
+	}
+}
+
+// Marquee scrolls "ETHER DREAM" through a window
+func Marquee(w io.WriteCloser) {
+	defer w.Close()
+	m := text.NewMarquee("ETHER DREAM", text.Style{Color: color.RGBA{0x00, 0xff, 0x44, 0xff}}, 30000)
+	for {
+		f, err := m.NextFrame(context.Background())
+		if err != nil {
+			return
+		}
+		for _, pt := range f.Points {
+			if _, err := w.Write(pt.Encode()); err != nil {
+				return
+			}
+		}
+		if etherdream.NextFrame(w, len(f.Points), f.Points[len(f.Points)-1]) < 0 {
+			return
+		}
+	}
+}
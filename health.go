@@ -0,0 +1,181 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"time"
+)
+
+// HistorySize is the number of status samples and ACK round trips
+// kept for each DAC
+var HistorySize = 1024
+
+// StatusSample is a status reply and the time it was received
+type StatusSample struct {
+	At     time.Time
+	Status DACStatus
+}
+
+// Health is a snapshot of metrics derived from a DAC's status history
+type Health struct {
+	DAC        string
+	Samples    int
+	Since      time.Time
+	LastStatus DACStatus
+
+	// buffer fullness over the samples taken while playing
+	AvgBufferFullness  float64
+	MinBufferFullness  uint16
+	RequestedPointRate uint32
+	ActualPointRate    float64
+	// AckLatency is over the recent round trips, AckTotal and Acks
+	// over every round trip since the DAC connected
+	AckLatency LatencyStats
+	AckTotal   time.Duration
+	Acks       int
+
+	PointsPlayed int
+	Underflows   int
+	NAKs         int
+	Reconnects   int
+	EStops       int
+}
+
+// statusHistory is a bounded ring of status samples and round trip
+// times, plus the event counters. It is read from other goroutines
+// so everything goes through the DAC's mutex.
+type statusHistory struct {
+	samples []StatusSample
+	next    int
+	rtts    []time.Duration
+	rnext   int
+	rttSum  time.Duration
+	rttN    int
+
+	requested  uint32
+	points     int
+	underflows int
+	naks       int
+	reconnects int
+	estops     int
+}
+
+func (h *statusHistory) addSample(st DACStatus) {
+	s := StatusSample{At: time.Now(), Status: st}
+	if len(h.samples) < HistorySize {
+		h.samples = append(h.samples, s)
+		return
+	}
+	h.samples[h.next] = s
+	h.next = (h.next + 1) % len(h.samples)
+}
+
+func (h *statusHistory) addRTT(rtt time.Duration) {
+	h.rttSum += rtt
+	h.rttN++
+	if len(h.rtts) < HistorySize {
+		h.rtts = append(h.rtts, rtt)
+		return
+	}
+	h.rtts[h.rnext] = rtt
+	h.rnext = (h.rnext + 1) % len(h.rtts)
+}
+
+// latest is the newest sample, false when there are none yet
+func (h *statusHistory) latest() (StatusSample, bool) {
+	if len(h.samples) == 0 {
+		return StatusSample{}, false
+	}
+	// next is 0 until the ring is full, then points at the oldest
+	iX := (h.next + len(h.samples) - 1) % len(h.samples)
+	return h.samples[iX], true
+}
+
+// ordered returns the samples oldest first
+func (h *statusHistory) ordered() []StatusSample {
+	ret := make([]StatusSample, 0, len(h.samples))
+	ret = append(ret, h.samples[h.next:]...)
+	return append(ret, h.samples[:h.next]...)
+}
+
+// History returns a copy of the recent status samples, oldest first
+func (d *DAC) History() []StatusSample {
+	d.hmu.Lock()
+	defer d.hmu.Unlock()
+	return d.history.ordered()
+}
+
+// Health derives a metrics snapshot from the status history
+func (d *DAC) Health() Health {
+	d.hmu.Lock()
+	defer d.hmu.Unlock()
+
+	h := &d.history
+	samples := h.ordered()
+	ret := Health{
+		DAC:                d.Host + ":" + d.Port,
+		Samples:            len(samples),
+		RequestedPointRate: h.requested,
+		AckLatency:         latencyStats(append([]time.Duration(nil), h.rtts...)),
+		AckTotal:           h.rttSum,
+		Acks:               h.rttN,
+		PointsPlayed:       h.points,
+		Underflows:         h.underflows,
+		NAKs:               h.naks,
+		Reconnects:         h.reconnects,
+		EStops:             h.estops,
+	}
+	if len(samples) == 0 {
+		return ret
+	}
+
+	ret.Since = samples[0].At
+	ret.LastStatus = samples[len(samples)-1].Status
+
+	var sum float64
+	var n int
+	var played uint32
+	var playing time.Duration
+	for iX, s := range samples {
+		// the buffer is empty when idle, that's no sign of trouble
+		if s.Status.PlaybackState == PlaybackPlaying {
+			if n == 0 || s.Status.BufferFullness < ret.MinBufferFullness {
+				ret.MinBufferFullness = s.Status.BufferFullness
+			}
+			sum += float64(s.Status.BufferFullness)
+			n++
+		}
+		// only count intervals where the DAC kept playing, the point
+		// count starts over after a prepare
+		if iX > 0 {
+			prev := samples[iX-1]
+			if prev.Status.PlaybackState == PlaybackPlaying &&
+				s.Status.PlaybackState == PlaybackPlaying &&
+				s.Status.PointCount >= prev.Status.PointCount {
+				played += s.Status.PointCount - prev.Status.PointCount
+				playing += s.At.Sub(prev.At)
+			}
+		}
+	}
+	if n > 0 {
+		ret.AvgBufferFullness = sum / float64(n)
+	}
+	if playing > 0 {
+		ret.ActualPointRate = float64(played) / playing.Seconds()
+	}
+	return ret
+}
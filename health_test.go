@@ -0,0 +1,76 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealthCountsPlayingOnly(t *testing.T) {
+	d := &DAC{Host: "test", Port: "7765"}
+	d.history.addSample(DACStatus{PlaybackState: PlaybackIdle})
+	d.history.addSample(DACStatus{PlaybackState: PlaybackPrepared, BufferFullness: 0})
+	d.history.addSample(DACStatus{PlaybackState: PlaybackPlaying, BufferFullness: 600})
+	d.history.addSample(DACStatus{PlaybackState: PlaybackPlaying, BufferFullness: 400})
+
+	h := d.Health()
+	if h.MinBufferFullness != 400 {
+		t.Errorf("MinBufferFullness = %v, want 400", h.MinBufferFullness)
+	}
+	if h.AvgBufferFullness != 500 {
+		t.Errorf("AvgBufferFullness = %v, want 500", h.AvgBufferFullness)
+	}
+}
+
+func TestMetricsLatencyIsCumulative(t *testing.T) {
+	defer func(n int) { HistorySize = n }(HistorySize)
+	HistorySize = 2
+
+	d := &DAC{Host: "test", Port: "7765"}
+	for iX := 0; iX < 5; iX++ {
+		d.history.addRTT(time.Millisecond)
+	}
+	var b bytes.Buffer
+	WriteMetrics(&b, []Health{d.Health()})
+	for _, want := range []string{
+		`etherdream_ack_latency_seconds_sum{dac="test:7765"} 0.005`,
+		`etherdream_ack_latency_seconds_count{dac="test:7765"} 5`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestStatusIsNewestSample(t *testing.T) {
+	defer func(n int) { HistorySize = n }(HistorySize)
+	HistorySize = 3
+
+	d := &DAC{}
+	if st := d.Status(); st != (DACStatus{}) {
+		t.Errorf("Status with no samples = %+v", st)
+	}
+	for iX := 1; iX <= 7; iX++ {
+		d.history.addSample(DACStatus{PointCount: uint32(iX)})
+		if st := d.Status(); st.PointCount != uint32(iX) {
+			t.Fatalf("after %v samples Status().PointCount = %v", iX, st.PointCount)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package idn speaks the ILDA Digital Network protocol, IDN-Hello and
+// IDN-Stream over UDP, that newer DACs and visualizers use. Output plays
+// frames to an IDN device with the same playback engine as an Ether
+// Dream, and Receiver is a local stand-in for one.
+package idn
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// Port is the UDP port IDN-Hello listens on
+const Port = 7255
+
+// IDN-Hello packet commands. The Ack variants of channel messages ask
+// the device to answer with CmdAcknowledge.
+const (
+	CmdPingRequest          = 0x08
+	CmdPingResponse         = 0x09
+	CmdScanRequest          = 0x10
+	CmdScanResponse         = 0x11
+	CmdServiceMapRequest    = 0x12
+	CmdServiceMapResponse   = 0x13
+	CmdChannelMessage       = 0x40
+	CmdChannelMessageAckReq = 0x41
+	CmdClose                = 0x44
+	CmdCloseAckReq          = 0x45
+	CmdAcknowledge          = 0x47
+)
+
+// IDN-Stream chunk types
+const (
+	ChunkVoid        = 0x00
+	ChunkWave        = 0x01
+	ChunkFrame       = 0x02
+	ChunkFrameFirst  = 0x03
+	ChunkFrameSequel = 0xc0
+)
+
+// Service modes of a laser projector graphics channel
+const (
+	// ModeContinuous streams points in chunks, like an Ether Dream
+	ModeContinuous = 0x01
+	// ModeDiscrete sends each frame whole, the device repeats it until
+	// the next one comes
+	ModeDiscrete = 0x02
+)
+
+// flags in the cnl byte of a channel message
+const (
+	cnlRouting = 0x80
+	cnlConfig  = 0x40
+	cnlID      = 0x3f
+)
+
+// flags of a channel configuration
+const (
+	cfgRouting = 0x01
+	cfgClose   = 0x02
+)
+
+const (
+	helloSize    = 4
+	messageSize  = 8
+	configSize   = 4
+	chunkSize    = 4
+	scanRespSize = 40
+	// maxDatagram is the largest UDP payload over IPv4, less than a
+	// channel message's size field can hold
+	maxDatagram = 65507
+)
+
+// xyrgb describes the samples we send: X and Y at 16 bits, then red,
+// green and blue at 8 bits
+var xyrgb = []uint16{
+	0x4200, 0x4010, // X, 16 bit precision
+	0x4210, 0x4010, // Y, 16 bit precision
+	0x527e, // red, 638nm
+	0x5214, // green, 532nm
+	0x51cc, // blue, 460nm
+	0x0000, // void, to pad to whole words
+}
+
+// sampleSize is the length of an xyrgb sample
+const sampleSize = 7
+
+// helloHeader is the 4 byte header of every packet
+func helloHeader(cmd byte, seq uint16) []byte {
+	b := make([]byte, helloSize, 1500)
+	b[0] = cmd
+	binary.BigEndian.PutUint16(b[2:4], seq)
+	return b
+}
+
+// message is one channel message, ready to add samples to
+type message struct {
+	channel   byte
+	chunk     byte
+	timestamp uint32
+	config    bool
+	close     bool
+	service   byte
+	mode      byte
+	duration  time.Duration
+	points    []etherdream.Point
+}
+
+// encode appends the channel message to a packet header
+func (m message) encode(b []byte) []byte {
+	start := len(b)
+	b = append(b, 0, 0, cnlRouting|m.channel&cnlID, m.chunk, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[start+4:], m.timestamp)
+	if m.config || m.close {
+		b[start+2] |= cnlConfig
+		flags := byte(cfgRouting)
+		words := len(xyrgb) / 2
+		if m.close {
+			flags |= cfgClose
+			words = 0
+		}
+		b = append(b, byte(words), flags, m.service, m.mode)
+		for iX := 0; iX < words*2; iX++ {
+			b = binary.BigEndian.AppendUint16(b, xyrgb[iX])
+		}
+	}
+	if m.chunk != ChunkVoid {
+		us := uint32(m.duration / time.Microsecond)
+		b = binary.BigEndian.AppendUint32(b, us&0xffffff)
+		for _, p := range m.points {
+			b = binary.BigEndian.AppendUint16(b, uint16(p.X))
+			b = binary.BigEndian.AppendUint16(b, uint16(p.Y))
+			b = append(b, byte(p.R>>8), byte(p.G>>8), byte(p.B>>8))
+		}
+	}
+	binary.BigEndian.PutUint16(b[start:], uint16(len(b)-start))
+	return b
+}
+
+// maxPoints is how many samples fit in a message of size bytes with
+// the configuration
+func maxPoints(size int) int {
+	return (size - helloSize - messageSize - configSize - len(xyrgb)*2 - chunkSize) / sampleSize
+}
+
+// layout is where each channel sits in a sample, from the descriptors
+// of a channel configuration. Offsets are -1 when missing.
+type layout struct {
+	size       int
+	x, y       field
+	r, g, b    field
+	mode       byte
+	configured bool
+}
+
+type field struct {
+	offset int
+	bits   int
+}
+
+// parseLayout reads a channel configuration's descriptors
+func parseLayout(desc []uint16) (layout, error) {
+	l := layout{x: field{-1, 0}, y: field{-1, 0}, r: field{-1, 0}, g: field{-1, 0}, b: field{-1, 0}}
+	for iX := 0; iX < len(desc); iX++ {
+		d := desc[iX]
+		if d == 0 {
+			continue
+		}
+		f := field{l.size, 8}
+		if iX+1 < len(desc) && desc[iX+1] == 0x4010 {
+			f.bits = 16
+			iX++
+		}
+		switch {
+		case d == 0x4200:
+			l.x = f
+		case d == 0x4210:
+			l.y = f
+		case d&0xfc00 == 0x5000:
+			// a color, by wavelength
+			nm := d & 0x3ff
+			switch {
+			case nm >= 600:
+				l.r = f
+			case nm >= 500:
+				l.g = f
+			default:
+				l.b = f
+			}
+		}
+		l.size += f.bits / 8
+	}
+	if l.x.offset < 0 || l.y.offset < 0 {
+		return l, fmt.Errorf("idn: channel has no X and Y")
+	}
+	l.configured = true
+	return l, nil
+}
+
+// value reads a field of a sample, scaled to 16 bits
+func (f field) value(s []byte) uint16 {
+	switch {
+	case f.offset < 0:
+		return 0
+	case f.bits == 16:
+		return binary.BigEndian.Uint16(s[f.offset:])
+	}
+	return uint16(s[f.offset]) * 0x101
+}
+
+// decode turns samples into points
+func (l layout) decode(b []byte) []etherdream.Point {
+	if l.size == 0 {
+		return nil
+	}
+	pts := make([]etherdream.Point, 0, len(b)/l.size)
+	for ; len(b) >= l.size; b = b[l.size:] {
+		p := etherdream.Point{
+			R: l.r.value(b),
+			G: l.g.value(b),
+			B: l.b.value(b),
+		}
+		// positions are signed, scaling by 0x101 keeps the sign of 8
+		// bit ones
+		p.X, p.Y = int16(l.x.value(b)), int16(l.y.value(b))
+		p.I = max(p.R, p.G, p.B)
+		pts = append(pts, p)
+	}
+	return pts
+}
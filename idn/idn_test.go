@@ -0,0 +1,384 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package idn
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image/color"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// The command codes of the IDN-Hello spec, checked by value so the
+// package can't agree with itself on wrong ones
+func TestCommandCodes(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		got, want byte
+	}{
+		{"ping request", CmdPingRequest, 0x08},
+		{"ping response", CmdPingResponse, 0x09},
+		{"scan request", CmdScanRequest, 0x10},
+		{"scan response", CmdScanResponse, 0x11},
+		{"service map request", CmdServiceMapRequest, 0x12},
+		{"service map response", CmdServiceMapResponse, 0x13},
+		{"channel message", CmdChannelMessage, 0x40},
+		{"channel message, ack requested", CmdChannelMessageAckReq, 0x41},
+		{"close", CmdClose, 0x44},
+		{"close, ack requested", CmdCloseAckReq, 0x45},
+		{"acknowledge", CmdAcknowledge, 0x47},
+	} {
+		if c.got != c.want {
+			t.Errorf("%v = %#02x, want %#02x", c.name, c.got, c.want)
+		}
+	}
+	if Port != 7255 {
+		t.Errorf("Port = %v, want 7255", Port)
+	}
+}
+
+func testPoints(n int) []etherdream.Point {
+	pts := make([]etherdream.Point, n)
+	for iX := range pts {
+		pts[iX] = *etherdream.NewPoint(iX*100-32768, 32767-iX*50, color.RGBA{uint8(iX), 0x80, 0xff, 0xff})
+	}
+	return pts
+}
+
+// decoded is what a point looks like after a trip through xyrgb, colors
+// keep their top 8 bits
+func decoded(p etherdream.Point) etherdream.Point {
+	ret := etherdream.Point{X: p.X, Y: p.Y, R: p.R >> 8 * 0x101, G: p.G >> 8 * 0x101, B: p.B >> 8 * 0x101}
+	ret.I = max(ret.R, ret.G, ret.B)
+	return ret
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	pts := testPoints(10)
+	m := message{
+		channel:   3,
+		chunk:     ChunkWave,
+		timestamp: 123456,
+		config:    true,
+		service:   1,
+		mode:      ModeContinuous,
+		duration:  500 * time.Microsecond,
+		points:    pts,
+	}
+	b := m.encode(helloHeader(CmdChannelMessage, 7))
+	if b[0] != 0x40 || binary.BigEndian.Uint16(b[2:4]) != 7 {
+		t.Fatalf("hello header % x", b[:helloSize])
+	}
+	b = b[helloSize:]
+	if size := int(binary.BigEndian.Uint16(b[0:2])); size != len(b) {
+		t.Errorf("message size %v, want %v", size, len(b))
+	}
+	if b[2] != cnlRouting|cnlConfig|3 || b[3] != ChunkWave {
+		t.Errorf("cnl %#02x chunk %#02x", b[2], b[3])
+	}
+	if ts := binary.BigEndian.Uint32(b[4:8]); ts != 123456 {
+		t.Errorf("timestamp %v", ts)
+	}
+
+	cfg := b[messageSize:]
+	words, flags, service, mode := int(cfg[0]), cfg[1], cfg[2], cfg[3]
+	if flags != cfgRouting || service != 1 || mode != ModeContinuous {
+		t.Errorf("config flags %#x service %v mode %v", flags, service, mode)
+	}
+	desc := make([]uint16, words*2)
+	for iX := range desc {
+		desc[iX] = binary.BigEndian.Uint16(cfg[configSize+iX*2:])
+	}
+	l, err := parseLayout(desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.size != sampleSize {
+		t.Errorf("sample size %v, want %v", l.size, sampleSize)
+	}
+
+	data := cfg[configSize+words*4:]
+	if us := binary.BigEndian.Uint32(data[0:4]) & 0xffffff; us != 500 {
+		t.Errorf("duration %vus, want 500", us)
+	}
+	got := l.decode(data[chunkSize:])
+	if len(got) != len(pts) {
+		t.Fatalf("decoded %v points, want %v", len(got), len(pts))
+	}
+	for iX, p := range pts {
+		if got[iX] != decoded(p) {
+			t.Errorf("point %v = %+v, want %+v", iX, got[iX], decoded(p))
+		}
+	}
+}
+
+func TestParseLayout8Bit(t *testing.T) {
+	// X and Y at 8 bits, only red
+	l, err := parseLayout([]uint16{0x4200, 0x4210, 0x527e, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pts := l.decode([]byte{0x7f, 0x80, 0xff, 0x00, 0x00, 0x00})
+	if len(pts) != 2 {
+		t.Fatalf("decoded %v points, want 2", len(pts))
+	}
+	if pts[0].X != 0x7f7f || pts[0].Y != -0x7f80 || pts[0].R != 0xffff || pts[0].G != 0 {
+		t.Errorf("point %+v", pts[0])
+	}
+
+	if _, err := parseLayout([]uint16{0x527e}); err == nil {
+		t.Errorf("layout with no X and Y parsed")
+	}
+}
+
+func receiver(t *testing.T) *Receiver {
+	t.Helper()
+	r, err := NewReceiver("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestScanReceiver(t *testing.T) {
+	r := receiver(t)
+	r.SetName("stage left")
+	units, err := Scan(r.Addr(), 500*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(units) != 1 {
+		t.Fatalf("found %v units, want 1", len(units))
+	}
+	u := units[0]
+	if u.Name != "stage left" || u.Version != 0x10 || string(u.UnitID) != "etherdream" {
+		t.Errorf("unit %+v", u)
+	}
+}
+
+func TestPingReceiver(t *testing.T) {
+	r := receiver(t)
+	conn, err := net.DialUDP("udp", nil, r.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	req := append(helloHeader(CmdPingRequest, 42), "hello"...)
+	if _, err := conn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 100)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(helloHeader(CmdPingResponse, 42), "hello"...)
+	if !bytes.Equal(b[:n], want) {
+		t.Errorf("ping response % x, want % x", b[:n], want)
+	}
+}
+
+func TestOutputToReceiver(t *testing.T) {
+	r := receiver(t)
+	o, err := Dial(r.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.ScanRate = 30000
+	o.MaxPoints = 100
+	o.Channel = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pts := testPoints(250)
+	if err := o.WriteFrame(ctx, etherdream.Frame{Points: pts}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []etherdream.Point
+	var at uint32
+	for len(got) < len(pts) {
+		c, err := r.NextChunk(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Channel != 2 || c.Type != ChunkWave {
+			t.Errorf("chunk channel %v type %v", c.Channel, c.Type)
+		}
+		// each chunk starts where the last one ended, give or take
+		// the rounding to whole microseconds
+		if len(got) > 0 && (c.Timestamp < at || c.Timestamp > at+1) {
+			t.Errorf("chunk at %vus, want %vus", c.Timestamp, at)
+		}
+		at = c.Timestamp + uint32(c.Duration/time.Microsecond)
+		got = append(got, c.Points...)
+	}
+	for iX, p := range pts {
+		if got[iX] != decoded(p) {
+			t.Fatalf("point %v = %+v, want %+v", iX, got[iX], decoded(p))
+		}
+	}
+	if st := o.Status(); st.PointCount != uint32(len(pts)) || st.PointRate != 30000 {
+		t.Errorf("status %+v", st)
+	}
+
+	// closing the channel drops its configuration
+	o.Close()
+	time.Sleep(50 * time.Millisecond)
+	r.mu.Lock()
+	_, ok := r.layouts[2]
+	r.mu.Unlock()
+	if ok {
+		t.Errorf("channel still configured after Close")
+	}
+}
+
+// chunks times n chunks of size points at rate the way Output does,
+// timestamps and durations in whole microseconds
+func chunks(n, size, rate int) []Chunk {
+	var ret []Chunk
+	var at time.Duration
+	for iX := 0; iX < n; iX++ {
+		d := time.Duration(size) * time.Second / time.Duration(rate)
+		ret = append(ret, Chunk{
+			Timestamp: uint32(at / time.Microsecond),
+			Duration:  d / time.Microsecond * time.Microsecond,
+			Points:    make([]etherdream.Point, size),
+		})
+		at += d
+	}
+	return ret
+}
+
+func TestRateMeter(t *testing.T) {
+	cs := chunks(400, 2, 30000)
+	if r := cs[0].Rate(); r != 30303 {
+		t.Errorf("short chunk Rate() = %v, the rounding this test is about has gone", r)
+	}
+
+	var m RateMeter
+	for _, c := range cs {
+		m.Add(c)
+	}
+	if r := m.Rate(); r < 29990 || r > 30010 {
+		t.Errorf("gapless run measures %v, want 30000", r)
+	}
+
+	// across a gap the timestamps don't count
+	m.Reset()
+	for iX, c := range cs {
+		if iX >= 200 {
+			c.Timestamp += 5000
+		}
+		m.Add(c)
+	}
+	if r := m.Rate(); r != 30303 {
+		t.Errorf("run with a gap measures %v, want the durations' 30303", r)
+	}
+
+	m.Reset()
+	if r := m.Rate(); r != 0 {
+		t.Errorf("empty meter measures %v", r)
+	}
+}
+
+// Status and Close don't wait on a frame being paced out
+func TestOutputPacingUnlocked(t *testing.T) {
+	r := receiver(t)
+	o, err := Dial(r.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.ScanRate = 1000
+	o.MaxPoints = 10
+
+	// a second of points, sent over most of that second
+	done := make(chan error, 1)
+	go func() {
+		done <- o.WriteFrame(context.Background(), etherdream.Frame{Points: testPoints(1000)})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	st := o.Status()
+	if since := time.Since(start); since > 20*time.Millisecond {
+		t.Errorf("Status took %v while pacing", since)
+	}
+	if st.PointCount == 0 || st.PointCount >= 1000 {
+		t.Errorf("%v points sent partway through the frame", st.PointCount)
+	}
+
+	start = time.Now()
+	o.Close()
+	if since := time.Since(start); since > 20*time.Millisecond {
+		t.Errorf("Close took %v while pacing", since)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("WriteFrame after Close = %v, want net.ErrClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("WriteFrame still pacing after Close")
+	}
+}
+
+// a discrete frame is one datagram, as big as UDP allows
+func TestOutputDiscreteLimit(t *testing.T) {
+	n := maxPoints(maxDatagram)
+	for _, c := range []struct {
+		points int
+		fits   bool
+	}{{n, true}, {n + 1, false}} {
+		m := message{chunk: ChunkFrame, config: true, mode: ModeDiscrete, points: testPoints(c.points)}
+		if size := len(m.encode(helloHeader(CmdChannelMessage, 0))); (size <= maxDatagram) != c.fits {
+			t.Errorf("%v points encode to %v bytes, limit %v", c.points, size, maxDatagram)
+		}
+	}
+
+	r := receiver(t)
+	o, err := Dial(r.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	o.Mode = ModeDiscrete
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := o.WriteFrame(ctx, etherdream.Frame{Points: testPoints(n + 1)}); err == nil {
+		t.Errorf("frame of %v points sent, over one datagram", n+1)
+	}
+	if err := o.WriteFrame(ctx, etherdream.Frame{Points: testPoints(n)}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := r.NextChunk(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Type != ChunkFrame || len(c.Points) != n {
+		t.Errorf("chunk type %v of %v points, want a frame of %v", c.Type, len(c.Points), n)
+	}
+}
@@ -0,0 +1,242 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package idn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// Unit is an IDN device that answered a scan
+type Unit struct {
+	Addr    *net.UDPAddr
+	Name    string
+	UnitID  []byte
+	Version byte
+	Status  byte
+}
+
+func (u Unit) String() string {
+	return fmt.Sprintf("%v (%v) IDN %d.%d", u.Name, u.Addr, u.Version>>4, u.Version&0xf)
+}
+
+// Discover broadcasts a scan on the LAN and returns the units that
+// answer within timeout
+func Discover(timeout time.Duration) ([]Unit, error) {
+	return Scan(&net.UDPAddr{IP: net.IPv4bcast, Port: Port}, timeout)
+}
+
+// Scan sends a scan request to addr, a broadcast or a single unit, and
+// returns the units that answer within timeout
+func Scan(addr *net.UDPAddr, timeout time.Duration) ([]Unit, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.WriteToUDP(helloHeader(CmdScanRequest, 1), addr); err != nil {
+		return nil, err
+	}
+
+	var units []Unit
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	b := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFromUDP(b)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return units, nil
+			}
+			return units, err
+		}
+		if n < helloSize+scanRespSize || b[0] != CmdScanResponse {
+			continue
+		}
+		r := b[helloSize:n]
+		idLen := min(int(r[4]), 15)
+		units = append(units, Unit{
+			Addr:    from,
+			Version: r[1],
+			Status:  r[2],
+			UnitID:  append([]byte(nil), r[5:5+idLen]...),
+			Name:    string(bytes.TrimRight(r[20:40], "\x00")),
+		})
+	}
+}
+
+// Output plays frames to an IDN device, an etherdream.Output. IDN has
+// no flow control, so points are timestamped and sent just ahead of
+// when they play, paced by the scan rate.
+type Output struct {
+	// ScanRate in points per second until a frame sets its Rate, the
+	// -scan-rate flag when 0
+	ScanRate int
+	// Mode is ModeContinuous, points in chunks as they play, or
+	// ModeDiscrete, each frame whole
+	Mode byte
+	// Channel and ServiceID route the stream on the device
+	Channel   byte
+	ServiceID byte
+	// MaxPoints in one continuous message, a 1500 byte packet when 0
+	MaxPoints int
+	// Lead is how far ahead of playing points are sent, 50ms when 0
+	Lead time.Duration
+
+	mu     sync.Mutex
+	conn   *net.UDPConn
+	seq    uint16
+	start  time.Time
+	at     time.Duration
+	points uint32
+	rate   int
+	closed bool
+}
+
+// Dial connects to an IDN device, host alone uses the standard port
+func Dial(host string) (*Output, error) {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, fmt.Sprint(Port))
+	}
+	addr, err := net.ResolveUDPAddr("udp", host)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Output{Mode: ModeContinuous, conn: conn}, nil
+}
+
+// WriteFrame sends f, waiting until it is nearly time to play it
+func (o *Output) WriteFrame(ctx context.Context, f etherdream.Frame) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return net.ErrClosed
+	}
+	rate := o.rate
+	if f.Rate > 0 {
+		rate = f.Rate
+	} else if rate <= 0 {
+		rate = o.ScanRate
+		if rate <= 0 {
+			rate = *etherdream.ScanRate
+		}
+	}
+	o.rate = rate
+	if o.start.IsZero() {
+		o.start = time.Now()
+	}
+	if behind := time.Since(o.start) - o.at; behind > 0 {
+		// underflow, the stream picks up from now
+		o.at += behind
+	}
+
+	if o.Mode == ModeDiscrete {
+		// a discrete frame goes in one datagram
+		if len(f.Points) > maxPoints(maxDatagram) {
+			return fmt.Errorf("idn: frame of %v points is too big for one message, use ModeContinuous", len(f.Points))
+		}
+		return o.send(ctx, ChunkFrame, f.Points, rate, true)
+	}
+	n := o.MaxPoints
+	if n <= 0 {
+		n = maxPoints(1500 - 28)
+	}
+	for iX := 0; iX < len(f.Points); iX += n {
+		end := min(iX+n, len(f.Points))
+		// the configuration rides along at the start of each frame
+		if err := o.send(ctx, ChunkWave, f.Points[iX:end], rate, iX == 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send waits until pts are Lead from playing and sends them as one
+// message. The lock is let go while it waits.
+func (o *Output) send(ctx context.Context, chunk byte, pts []etherdream.Point, rate int, config bool) error {
+	lead := o.Lead
+	if lead <= 0 {
+		lead = 50 * time.Millisecond
+	}
+	if wait := o.at - lead - time.Since(o.start); wait > 0 {
+		o.mu.Unlock()
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			o.mu.Lock()
+			return ctx.Err()
+		}
+		o.mu.Lock()
+		if o.closed {
+			return net.ErrClosed
+		}
+	}
+
+	d := time.Duration(len(pts)) * time.Second / time.Duration(rate)
+	m := message{
+		channel:   o.Channel,
+		chunk:     chunk,
+		timestamp: uint32(o.at / time.Microsecond),
+		config:    config,
+		service:   o.ServiceID,
+		mode:      o.Mode,
+		duration:  d,
+		points:    pts,
+	}
+	b := m.encode(helloHeader(CmdChannelMessage, o.seq))
+	o.seq++
+	if _, err := o.conn.Write(b); err != nil {
+		return err
+	}
+	o.at += d
+	o.points += uint32(len(pts))
+	return nil
+}
+
+// Status reports the points sent so far, IDN devices don't send status
+func (o *Output) Status() etherdream.DACStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	st := etherdream.DACStatus{PointCount: o.points}
+	if !o.start.IsZero() && !o.closed {
+		st.PlaybackState = etherdream.PlaybackPlaying
+		st.PointRate = uint32(o.rate)
+	}
+	return st
+}
+
+// Close tells the device the channel is done and closes the socket
+func (o *Output) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	m := message{channel: o.Channel, chunk: ChunkVoid, timestamp: uint32(o.at / time.Microsecond), close: true}
+	o.conn.Write(m.encode(helloHeader(CmdClose, o.seq)))
+	return o.conn.Close()
+}
@@ -0,0 +1,302 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package idn
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// Chunk is the samples of one channel message
+type Chunk struct {
+	From    *net.UDPAddr
+	Channel byte
+	// Type is ChunkWave or ChunkFrame
+	Type byte
+	// Timestamp of the first sample in microseconds
+	Timestamp uint32
+	// Duration the samples take to play
+	Duration time.Duration
+	Points   []etherdream.Point
+}
+
+// Rate is the point rate the chunk plays at. Durations are sent in
+// whole microseconds, so for a short chunk this is only roughly right,
+// 2 points at 30000 come out as 30303. Use a RateMeter when it matters.
+func (c Chunk) Rate() int {
+	if c.Duration <= 0 {
+		return 0
+	}
+	return int(time.Duration(len(c.Points)) * time.Second / c.Duration)
+}
+
+// RateMeter measures the point rate over a run of chunks. While the
+// chunks follow on from each other it times them by their timestamps,
+// which don't gather rounding like the durations do. Across a gap it
+// falls back to adding up the durations.
+type RateMeter struct {
+	points     int
+	dur        time.Duration
+	chunks     int
+	start, end uint32
+}
+
+// Add counts a chunk
+func (m *RateMeter) Add(c Chunk) {
+	if m.chunks == 0 {
+		m.start = c.Timestamp
+	}
+	m.points += len(c.Points)
+	m.dur += c.Duration
+	m.chunks++
+	m.end = c.Timestamp + uint32(c.Duration/time.Microsecond)
+}
+
+// Points counted since the last Reset
+func (m *RateMeter) Points() int {
+	return m.points
+}
+
+// Duration of the chunks counted since the last Reset
+func (m *RateMeter) Duration() time.Duration {
+	return m.dur
+}
+
+// Rate is the points per second of the chunks counted, 0 before any
+// have play time
+func (m *RateMeter) Rate() int {
+	d := m.dur
+	// each duration loses under a microsecond, so a gapless run spans
+	// a little more than they add up to
+	span := time.Duration(m.end-m.start) * time.Microsecond
+	if slack := span - d; slack >= -time.Microsecond && slack <= time.Duration(m.chunks+1)*time.Microsecond {
+		d = span
+	}
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Round(float64(m.points) / d.Seconds()))
+}
+
+// Reset starts a new measurement
+func (m *RateMeter) Reset() {
+	*m = RateMeter{}
+}
+
+// Receiver is a local stand-in for an IDN device. It answers scans and
+// decodes the channel messages sent to it. It is also a FrameSource, so
+// what it receives can be previewed or played on.
+type Receiver struct {
+	conn    *net.UDPConn
+	chunks  chan Chunk
+	mu      sync.Mutex
+	layouts map[byte]layout
+	name    string
+	dropped int
+	pending []etherdream.Point
+	// the rate of the chunks gathered into pending
+	meter RateMeter
+}
+
+// NewReceiver listens for IDN on addr, use "127.0.0.1:0" to pick a free
+// port
+func NewReceiver(addr string) (*Receiver, error) {
+	ua, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", ua)
+	if err != nil {
+		return nil, err
+	}
+	r := &Receiver{
+		name:    "etherdream idn",
+		conn:    conn,
+		chunks:  make(chan Chunk, 256),
+		layouts: map[byte]layout{},
+	}
+	go r.serve()
+	return r, nil
+}
+
+// Addr is the address the receiver is listening on
+func (r *Receiver) Addr() *net.UDPAddr {
+	return r.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// SetName sets the host name sent in scan responses
+func (r *Receiver) SetName(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.name = name
+}
+
+// Close stops listening, NextChunk then returns io.EOF
+func (r *Receiver) Close() error {
+	return r.conn.Close()
+}
+
+// Dropped is the number of chunks thrown away, because nobody was
+// reading them or the channel had no configuration yet
+func (r *Receiver) Dropped() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+func (r *Receiver) serve() {
+	defer close(r.chunks)
+	b := make([]byte, 0x10000)
+	for {
+		n, from, err := r.conn.ReadFromUDP(b)
+		if err != nil {
+			return
+		}
+		if n < helloSize {
+			continue
+		}
+		switch b[0] {
+		case CmdPingRequest:
+			// the payload is echoed back
+			resp := append(helloHeader(CmdPingResponse, binary.BigEndian.Uint16(b[2:4])), b[helloSize:n]...)
+			r.conn.WriteToUDP(resp, from)
+		case CmdScanRequest:
+			r.conn.WriteToUDP(r.scanResponse(binary.BigEndian.Uint16(b[2:4])), from)
+		case CmdChannelMessage, CmdChannelMessageAckReq, CmdClose, CmdCloseAckReq:
+			r.message(b[helloSize:n], from)
+		}
+	}
+}
+
+func (r *Receiver) scanResponse(seq uint16) []byte {
+	b := helloHeader(CmdScanResponse, seq)
+	resp := make([]byte, scanRespSize)
+	resp[0] = scanRespSize
+	// IDN 1.0
+	resp[1] = 0x10
+	id := []byte("etherdream")
+	resp[4] = byte(len(id))
+	copy(resp[5:20], id)
+	r.mu.Lock()
+	copy(resp[20:40], r.name)
+	r.mu.Unlock()
+	return append(b, resp...)
+}
+
+// message decodes one channel message
+func (r *Receiver) message(b []byte, from *net.UDPAddr) {
+	if len(b) < messageSize {
+		return
+	}
+	size := int(binary.BigEndian.Uint16(b[0:2]))
+	if size < messageSize || size > len(b) {
+		return
+	}
+	b = b[:size]
+	cnl, chunk := b[2], b[3]
+	ch := cnl & cnlID
+	c := Chunk{From: from, Channel: ch, Type: chunk, Timestamp: binary.BigEndian.Uint32(b[4:8])}
+	b = b[messageSize:]
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cnl&cnlConfig != 0 {
+		if len(b) < configSize {
+			return
+		}
+		words, flags, mode := int(b[0]), b[1], b[3]
+		if len(b) < configSize+words*4 {
+			return
+		}
+		desc := make([]uint16, words*2)
+		for iX := range desc {
+			desc[iX] = binary.BigEndian.Uint16(b[configSize+iX*2:])
+		}
+		b = b[configSize+words*4:]
+		if flags&cfgClose != 0 {
+			delete(r.layouts, ch)
+			return
+		}
+		if words > 0 {
+			l, err := parseLayout(desc)
+			if err != nil {
+				return
+			}
+			l.mode = mode
+			r.layouts[ch] = l
+		}
+	}
+	if chunk != ChunkWave && chunk != ChunkFrame {
+		return
+	}
+	l, ok := r.layouts[ch]
+	if !ok || len(b) < chunkSize {
+		r.dropped++
+		return
+	}
+	c.Duration = time.Duration(binary.BigEndian.Uint32(b[0:4])&0xffffff) * time.Microsecond
+	c.Points = l.decode(b[chunkSize:])
+
+	select {
+	case r.chunks <- c:
+	default:
+		r.dropped++
+	}
+}
+
+// NextChunk waits for the next chunk of samples
+func (r *Receiver) NextChunk(ctx context.Context) (Chunk, error) {
+	select {
+	case c, ok := <-r.chunks:
+		if !ok {
+			return Chunk{}, io.EOF
+		}
+		return c, nil
+	case <-ctx.Done():
+		return Chunk{}, ctx.Err()
+	}
+}
+
+// NextFrame waits for the next frame. Frame chunks are frames already,
+// continuous chunks are gathered FramePoints() at a time. The frame's
+// Rate is measured over the chunks gathered for it with a RateMeter.
+func (r *Receiver) NextFrame(ctx context.Context) (etherdream.Frame, error) {
+	for {
+		c, err := r.NextChunk(ctx)
+		if err != nil {
+			return etherdream.Frame{}, err
+		}
+		if c.Type == ChunkFrame {
+			return etherdream.Frame{Points: c.Points, Rate: c.Rate()}, nil
+		}
+		r.pending = append(r.pending, c.Points...)
+		r.meter.Add(c)
+		if n := etherdream.FramePoints(); len(r.pending) >= n {
+			f := etherdream.Frame{Points: r.pending[:n:n], Rate: r.meter.Rate()}
+			r.meter.Reset()
+			r.pending = append([]etherdream.Point(nil), r.pending[n:]...)
+			return f, nil
+		}
+	}
+}
@@ -0,0 +1,60 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package ilda reads ILDA Image Data Transfer Format (.ild) files, the
+// interchange format exported by laser show software, and turns them
+// into frames that can be played on an Ether Dream.
+package ilda
+
+import (
+	"github.com/tgreiser/etherdream"
+)
+
+// Format codes for the sections of an ILDA file
+const (
+	Format3DIndexed   = 0
+	Format2DIndexed   = 1
+	FormatPalette     = 2
+	Format3DTrueColor = 4
+	Format2DTrueColor = 5
+)
+
+// Point status bits
+const (
+	StatusLastPoint = 0x80
+	StatusBlanked   = 0x40
+)
+
+// headerSize is the length of every section header
+const headerSize = 32
+
+// Header is the 32 byte header in front of every section
+type Header struct {
+	Format    byte
+	Name      string
+	Company   string
+	Records   int
+	Number    int
+	Total     int
+	Projector byte
+}
+
+// Frame is one image from an ILDA file. Coordinates map directly to
+// the DAC range, Z is dropped from 3D frames.
+type Frame struct {
+	Header
+	Points []etherdream.Point
+}
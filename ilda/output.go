@@ -0,0 +1,74 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ilda
+
+import (
+	"context"
+	"os"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// FileOutput records the frames played to it as an ILDA file, an
+// etherdream.Output. The file is written when it is closed.
+type FileOutput struct {
+	*Writer
+	f      *os.File
+	points uint32
+}
+
+// Create records to the named file in Format3DTrueColor or
+// Format2DTrueColor
+func Create(name string, format byte) (*FileOutput, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWriter(f, format)
+	if err != nil {
+		f.Close()
+		os.Remove(name)
+		return nil, err
+	}
+	return &FileOutput{Writer: w, f: f}, nil
+}
+
+// WriteFrame adds a frame to the file
+func (o *FileOutput) WriteFrame(ctx context.Context, f etherdream.Frame) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	o.points += uint32(len(f.Points))
+	return o.Writer.WriteFrame(f)
+}
+
+// Status reports the points recorded so far
+func (o *FileOutput) Status() etherdream.DACStatus {
+	return etherdream.DACStatus{
+		PlaybackState: etherdream.PlaybackPlaying,
+		PointCount:    o.points,
+	}
+}
+
+// Close writes the file
+func (o *FileOutput) Close() error {
+	err := o.Writer.Close()
+	if cerr := o.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
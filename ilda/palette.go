@@ -0,0 +1,40 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ilda
+
+import "image/color"
+
+// DefaultPalette is the 64 color standard ILDA palette, used for
+// indexed frames until the file supplies its own palette.
+var DefaultPalette = []color.RGBA{
+	{255, 0, 0, 255}, {255, 16, 0, 255}, {255, 32, 0, 255}, {255, 48, 0, 255},
+	{255, 64, 0, 255}, {255, 80, 0, 255}, {255, 96, 0, 255}, {255, 112, 0, 255},
+	{255, 128, 0, 255}, {255, 144, 0, 255}, {255, 160, 0, 255}, {255, 176, 0, 255},
+	{255, 192, 0, 255}, {255, 208, 0, 255}, {255, 224, 0, 255}, {255, 240, 0, 255},
+	{255, 255, 0, 255}, {224, 255, 0, 255}, {192, 255, 0, 255}, {160, 255, 0, 255},
+	{128, 255, 0, 255}, {96, 255, 0, 255}, {64, 255, 0, 255}, {32, 255, 0, 255},
+	{0, 255, 0, 255}, {0, 255, 36, 255}, {0, 255, 73, 255}, {0, 255, 109, 255},
+	{0, 255, 146, 255}, {0, 255, 182, 255}, {0, 255, 219, 255}, {0, 255, 255, 255},
+	{0, 227, 255, 255}, {0, 198, 255, 255}, {0, 170, 255, 255}, {0, 142, 255, 255},
+	{0, 113, 255, 255}, {0, 85, 255, 255}, {0, 56, 255, 255}, {0, 28, 255, 255},
+	{0, 0, 255, 255}, {32, 0, 255, 255}, {64, 0, 255, 255}, {96, 0, 255, 255},
+	{128, 0, 255, 255}, {160, 0, 255, 255}, {192, 0, 255, 255}, {224, 0, 255, 255},
+	{255, 0, 255, 255}, {255, 32, 255, 255}, {255, 64, 255, 255}, {255, 96, 255, 255},
+	{255, 128, 255, 255}, {255, 160, 255, 255}, {255, 192, 255, 255}, {255, 224, 255, 255},
+	{255, 255, 255, 255}, {255, 224, 224, 255}, {255, 192, 192, 255}, {255, 160, 160, 255},
+	{255, 128, 128, 255}, {255, 96, 96, 255}, {255, 64, 64, 255}, {255, 32, 32, 255},
+}
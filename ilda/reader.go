@@ -0,0 +1,213 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ilda
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// FormatError is returned for data that is not a valid ILDA file
+type FormatError struct {
+	Msg string
+}
+
+func (e *FormatError) Error() string {
+	return "ilda: " + e.Msg
+}
+
+// Reader reads frames from an ILDA file one at a time. Palette
+// sections are applied to the indexed frames that follow them.
+type Reader struct {
+	r       *bufio.Reader
+	Palette []color.RGBA
+}
+
+// NewReader starts reading an ILDA file with the default palette
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		r:       bufio.NewReader(r),
+		Palette: DefaultPalette,
+	}
+}
+
+// ReadFile reads every frame in the named file
+func ReadFile(name string) ([]Frame, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadAll(f)
+}
+
+// ReadAll reads frames until the end of file section
+func ReadAll(r io.Reader) ([]Frame, error) {
+	ir := NewReader(r)
+	var frames []Frame
+	for {
+		f, err := ir.Next()
+		if err == io.EOF {
+			return frames, nil
+		}
+		if err != nil {
+			return frames, err
+		}
+		frames = append(frames, *f)
+	}
+}
+
+// Next returns the next frame, it returns io.EOF after the end of
+// file header or at the end of the data.
+func (r *Reader) Next() (*Frame, error) {
+	for {
+		h, err := r.header()
+		if err != nil {
+			return nil, err
+		}
+		if h.Records == 0 {
+			return nil, io.EOF
+		}
+
+		switch h.Format {
+		case FormatPalette:
+			if err = r.palette(h); err != nil {
+				return nil, err
+			}
+		case Format3DIndexed, Format2DIndexed, Format3DTrueColor, Format2DTrueColor:
+			return r.frame(h)
+		default:
+			return nil, &FormatError{fmt.Sprintf("unsupported format %d", h.Format)}
+		}
+	}
+}
+
+func (r *Reader) header() (Header, error) {
+	var b [headerSize]byte
+	if _, err := io.ReadFull(r.r, b[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Header{}, &FormatError{"truncated header"}
+		}
+		return Header{}, err
+	}
+	if string(b[0:4]) != "ILDA" {
+		return Header{}, &FormatError{"missing ILDA signature"}
+	}
+	return Header{
+		Format:    b[7],
+		Name:      trimName(b[8:16]),
+		Company:   trimName(b[16:24]),
+		Records:   int(binary.BigEndian.Uint16(b[24:26])),
+		Number:    int(binary.BigEndian.Uint16(b[26:28])),
+		Total:     int(binary.BigEndian.Uint16(b[28:30])),
+		Projector: b[30],
+	}, nil
+}
+
+func trimName(b []byte) string {
+	return strings.TrimRight(string(b), "\x00 ")
+}
+
+func (r *Reader) palette(h Header) error {
+	b, err := r.records(h.Records, 3)
+	if err != nil {
+		return err
+	}
+	pal := make([]color.RGBA, h.Records)
+	for iX := range pal {
+		c := b[iX*3:]
+		pal[iX] = color.RGBA{c[0], c[1], c[2], 0xff}
+	}
+	r.Palette = pal
+	return nil
+}
+
+func (r *Reader) frame(h Header) (*Frame, error) {
+	size := recordSize(h.Format)
+	b, err := r.records(h.Records, size)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Frame{Header: h, Points: make([]etherdream.Point, h.Records)}
+	for iX := range f.Points {
+		rec := b[iX*size : (iX+1)*size]
+		x := int(int16(binary.BigEndian.Uint16(rec[0:2])))
+		y := int(int16(binary.BigEndian.Uint16(rec[2:4])))
+		// skip Z on 3D records
+		st := rec[4:]
+		if h.Format == Format3DIndexed || h.Format == Format3DTrueColor {
+			st = rec[6:]
+		}
+		status := st[0]
+
+		var c color.Color = etherdream.BlankColor
+		if status&StatusBlanked == 0 {
+			switch h.Format {
+			case Format3DIndexed, Format2DIndexed:
+				c = r.color(st[1])
+			default:
+				// true color records are stored blue, green, red
+				c = color.RGBA{st[3], st[2], st[1], 0xff}
+			}
+		}
+		f.Points[iX] = *etherdream.NewPoint(x, y, c)
+	}
+	return f, nil
+}
+
+func (r *Reader) color(idx byte) color.Color {
+	if int(idx) >= len(r.Palette) {
+		return etherdream.BlankColor
+	}
+	return r.Palette[idx]
+}
+
+func (r *Reader) records(n, size int) ([]byte, error) {
+	b := make([]byte, n*size)
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, &FormatError{"truncated section"}
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// recordSize in bytes for each format
+func recordSize(format byte) int {
+	switch format {
+	case Format3DIndexed:
+		return 8
+	case Format2DIndexed:
+		return 6
+	case FormatPalette:
+		return 3
+	case Format3DTrueColor:
+		return 10
+	case Format2DTrueColor:
+		return 8
+	}
+	return 0
+}
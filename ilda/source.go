@@ -0,0 +1,75 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ilda
+
+import (
+	"context"
+	"io"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// Source plays frames at fps frames per second. Each frame is drawn as
+// many whole times as fit in ScanRate / fps points, then padded with
+// blank points so the timing holds. Frames too big for the budget are
+// drawn once and play slower. With loop the frames repeat forever,
+// otherwise the source ends after the last frame. An fps of 0 or less
+// plays at the default frame rate, FramePoints() points a frame.
+func Source(frames []Frame, fps int, loop bool) etherdream.FrameSource {
+	iX := 0
+	return etherdream.FrameSourceFunc(func(ctx context.Context) (etherdream.Frame, error) {
+		if err := ctx.Err(); err != nil {
+			return etherdream.Frame{}, err
+		}
+		if iX >= len(frames) {
+			if !loop || len(frames) == 0 {
+				return etherdream.Frame{}, io.EOF
+			}
+			iX = 0
+		}
+		f := frames[iX]
+		iX++
+		budget := etherdream.FramePoints()
+		if fps > 0 {
+			budget = etherdream.FrameBudget(*etherdream.ScanRate, fps)
+		}
+		return Fill(f.Points, budget), nil
+	})
+}
+
+// Fill repeats pts to fill a budget of n points, then pads the rest
+// with blank points at the last position.
+func Fill(pts []etherdream.Point, n int) etherdream.Frame {
+	if len(pts) == 0 {
+		return etherdream.Frame{}
+	}
+	times := n / len(pts)
+	if times < 1 {
+		times = 1
+	}
+
+	out := make([]etherdream.Point, 0, n)
+	for iX := 0; iX < times; iX++ {
+		out = append(out, pts...)
+	}
+	last := pts[len(pts)-1]
+	blank := *etherdream.NewPoint(int(last.X), int(last.Y), etherdream.BlankColor)
+	for len(out) < n {
+		out = append(out, blank)
+	}
+	return etherdream.Frame{Points: out}
+}
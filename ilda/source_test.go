@@ -0,0 +1,58 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ilda
+
+import (
+	"context"
+	"image/color"
+	"io"
+	"testing"
+
+	"github.com/tgreiser/etherdream"
+)
+
+func TestSourceFill(t *testing.T) {
+	pts := []etherdream.Point{
+		*etherdream.NewPoint(0, 0, color.White),
+		*etherdream.NewPoint(100, 100, color.White),
+		*etherdream.NewPoint(200, 0, color.White),
+	}
+	for _, fps := range []int{30, 0, -1} {
+		src := Source([]Frame{{Points: pts}}, fps, false)
+		f, err := src.NextFrame(context.Background())
+		if err != nil {
+			t.Fatalf("fps %v: %v", fps, err)
+		}
+		want := etherdream.FramePoints()
+		if fps > 0 {
+			want = *etherdream.ScanRate / fps
+		}
+		if len(f.Points) != want {
+			t.Errorf("fps %v: %v points, want %v", fps, len(f.Points), want)
+		}
+		// drawn whole as many times as fit, then blanked
+		if f.Points[len(pts)] != pts[0] {
+			t.Errorf("fps %v: frame isn't repeated", fps)
+		}
+		if last := f.Points[len(f.Points)-1]; last.R != 0 || last.X != 200 {
+			t.Errorf("fps %v: padding %+v, want blank at the last point", fps, last)
+		}
+		if _, err := src.NextFrame(context.Background()); err != io.EOF {
+			t.Errorf("fps %v: err = %v after the last frame, want EOF", fps, err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ilda
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// maxRecords is the most points a single ILDA frame can hold, and
+// maxFrames the most frames in a file, both are 16 bit header fields
+const (
+	maxRecords = 0xffff
+	maxFrames  = 0xffff
+)
+
+// Writer writes frames as an ILDA file in format 4 or 5. The header of
+// every frame carries the total frame count, so frames are collected and
+// the file is written on Close.
+//
+// Writer is also a FrameWriter, a PointStream can write encoded points
+// to it directly and NextFrame marks the frame boundaries.
+type Writer struct {
+	Name      string
+	Company   string
+	Projector byte
+
+	w      io.Writer
+	format byte
+	frames [][]etherdream.Point
+	cur    []etherdream.Point
+	part   []byte
+	closed bool
+}
+
+// NewWriter writes to w in Format3DTrueColor or Format2DTrueColor
+func NewWriter(w io.Writer, format byte) (*Writer, error) {
+	if format != Format3DTrueColor && format != Format2DTrueColor {
+		return nil, &FormatError{fmt.Sprintf("cannot write format %d", format)}
+	}
+	return &Writer{w: w, format: format}, nil
+}
+
+// WriteFrame adds a frame to the file
+func (w *Writer) WriteFrame(f etherdream.Frame) error {
+	if w.closed {
+		return io.ErrClosedPipe
+	}
+	return w.add(f.Points)
+}
+
+// Write takes encoded 18 byte points, as written by a PointStream
+func (w *Writer) Write(b []byte) (int, error) {
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	ps := int(etherdream.PointSize)
+	w.part = append(w.part, b...)
+	n := len(w.part) / ps
+	for iX := 0; iX < n; iX++ {
+		w.cur = append(w.cur, etherdream.DecodePoint(w.part[iX*ps:]))
+	}
+	w.part = append(w.part[:0], w.part[n*ps:]...)
+	return len(b), nil
+}
+
+// EndFrame ends the frame being written with Write
+func (w *Writer) EndFrame() error {
+	if w.closed {
+		return io.ErrClosedPipe
+	}
+	err := w.add(w.cur)
+	w.cur = nil
+	return err
+}
+
+// add splits frames too big for one ILDA section. The frame count is
+// a 16 bit field, so a file holds no more than maxFrames.
+func (w *Writer) add(pts []etherdream.Point) error {
+	need := (len(pts) + maxRecords - 1) / maxRecords
+	if len(w.frames)+need > maxFrames {
+		return &FormatError{fmt.Sprintf("more than %d frames", maxFrames)}
+	}
+	for len(pts) > maxRecords {
+		w.frames = append(w.frames, pts[:maxRecords])
+		pts = pts[maxRecords:]
+	}
+	if len(pts) > 0 {
+		w.frames = append(w.frames, pts)
+	}
+	return nil
+}
+
+// Close ends any partial frame and writes the file
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	err := w.add(w.cur)
+	w.cur = nil
+	w.closed = true
+	if err != nil {
+		return err
+	}
+
+	for iX, pts := range w.frames {
+		if err := w.writeFrame(iX, pts); err != nil {
+			return err
+		}
+	}
+	// a header with no records ends the file
+	_, err = w.w.Write(w.header(0, 0))
+	return err
+}
+
+func (w *Writer) header(records, number int) []byte {
+	h := make([]byte, headerSize)
+	copy(h[0:4], "ILDA")
+	h[7] = w.format
+	copy(h[8:16], w.Name)
+	copy(h[16:24], w.Company)
+	binary.BigEndian.PutUint16(h[24:26], uint16(records))
+	binary.BigEndian.PutUint16(h[26:28], uint16(number))
+	binary.BigEndian.PutUint16(h[28:30], uint16(len(w.frames)))
+	h[30] = w.Projector
+	return h
+}
+
+func (w *Writer) writeFrame(number int, pts []etherdream.Point) error {
+	size := recordSize(w.format)
+	b := make([]byte, len(pts)*size)
+	for iX, p := range pts {
+		rec := b[iX*size : (iX+1)*size]
+		binary.BigEndian.PutUint16(rec[0:2], uint16(p.X))
+		binary.BigEndian.PutUint16(rec[2:4], uint16(p.Y))
+		st := rec[4:]
+		if w.format == Format3DTrueColor {
+			// Z stays 0
+			st = rec[6:]
+		}
+
+		r, g, bl := byte(p.R>>8), byte(p.G>>8), byte(p.B>>8)
+		if r == 0 && g == 0 && bl == 0 {
+			st[0] |= StatusBlanked
+		}
+		if iX == len(pts)-1 {
+			st[0] |= StatusLastPoint
+		}
+		st[1], st[2], st[3] = bl, g, r
+	}
+
+	if _, err := w.w.Write(w.header(len(pts), number)); err != nil {
+		return err
+	}
+	_, err := w.w.Write(b)
+	return err
+}
+
+// Capture writes up to n frames from src to w as an ILDA file. It stops
+// early if the source ends.
+func Capture(ctx context.Context, w io.Writer, format byte, src etherdream.FrameSource, n int) error {
+	iw, err := NewWriter(w, format)
+	if err != nil {
+		return err
+	}
+	for iX := 0; iX < n; iX++ {
+		f, err := src.NextFrame(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := iw.WriteFrame(f); err != nil {
+			return err
+		}
+	}
+	return iw.Close()
+}
+
+// Record captures n frames of a PointStream to w as an ILDA file
+func Record(w io.Writer, format byte, stream etherdream.PointStream, n int) error {
+	src := etherdream.NewStreamSource(stream)
+	defer src.Close()
+	return Capture(context.Background(), w, format, src, n)
+}
@@ -0,0 +1,97 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ilda
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/tgreiser/etherdream"
+)
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	for _, format := range []byte{Format2DTrueColor, Format3DTrueColor} {
+		frames := [][]etherdream.Point{
+			{
+				*etherdream.NewPoint(-32768, 32767, color.RGBA{0xff, 0x80, 0x01, 0xff}),
+				*etherdream.NewPoint(0, 0, etherdream.BlankColor),
+				*etherdream.NewPoint(1234, -4321, color.RGBA{0, 0, 0xff, 0xff}),
+			},
+			{*etherdream.NewPoint(5, 6, color.RGBA{0, 0xff, 0, 0xff})},
+		}
+
+		var b bytes.Buffer
+		w, err := NewWriter(&b, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Name = "frames"
+		for _, pts := range frames {
+			if err := w.WriteFrame(etherdream.Frame{Points: pts}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ReadAll(&b)
+		if err != nil {
+			t.Fatalf("format %v: %v", format, err)
+		}
+		if len(got) != len(frames) {
+			t.Fatalf("format %v: read %v frames, want %v", format, len(got), len(frames))
+		}
+		for iX, f := range got {
+			if f.Format != format || f.Name != "frames" || f.Number != iX || f.Total != len(frames) {
+				t.Errorf("format %v: header %+v", format, f.Header)
+			}
+			if len(f.Points) != len(frames[iX]) {
+				t.Fatalf("format %v frame %v: %v points, want %v", format, iX, len(f.Points), len(frames[iX]))
+			}
+			for iY, p := range f.Points {
+				// colors keep their top 8 bits
+				want := frames[iX][iY]
+				want.R, want.G, want.B = want.R&0xff00|want.R>>8, want.G&0xff00|want.G>>8, want.B&0xff00|want.B>>8
+				want.I = 0
+				p.I = 0
+				if p != want {
+					t.Errorf("format %v frame %v point %v = %+v, want %+v", format, iX, iY, p, want)
+				}
+			}
+		}
+	}
+}
+
+func TestWriterFrameLimit(t *testing.T) {
+	w, err := NewWriter(&bytes.Buffer{}, Format2DTrueColor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt := []etherdream.Point{*etherdream.NewPoint(0, 0, color.White)}
+	for iX := 0; iX < maxFrames; iX++ {
+		if err := w.WriteFrame(etherdream.Frame{Points: pt}); err != nil {
+			t.Fatalf("frame %v: %v", iX, err)
+		}
+	}
+	var fe *FormatError
+	if err := w.WriteFrame(etherdream.Frame{Points: pt}); !errors.As(err, &fe) {
+		t.Errorf("frame %v: err = %v, want a FormatError", maxFrames, err)
+	}
+}
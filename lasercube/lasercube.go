@@ -0,0 +1,385 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package lasercube plays to LaserCubes over their UDP network protocol,
+// with commands on one port and point data on another. Output is an
+// etherdream.Output, so one PointStream can drive a LaserCube or an
+// Ether Dream.
+package lasercube
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// UDP ports of a LaserCube
+const (
+	AlivePort = 45456
+	CmdPort   = 45457
+	DataPort  = 45458
+)
+
+// Commands, sent to CmdPort apart from sample data
+const (
+	CmdGetFullInfo          = 0x77
+	CmdEnableBufferResponse = 0x78
+	CmdSetOutput            = 0x80
+	CmdSetRate              = 0x82
+	CmdGetBufferFree        = 0x8a
+	CmdSampleData           = 0xa9
+)
+
+const (
+	// samplesPerPacket keeps data packets under the MTU
+	samplesPerPacket = 140
+	sampleSize       = 10
+	infoSize         = 38
+	// pollInterval spaces out buffer polls when replies stop coming
+	pollInterval = 30 * time.Millisecond
+)
+
+// Info is what a LaserCube reports about itself
+type Info struct {
+	Addr          *net.UDPAddr
+	Firmware      string
+	OutputEnabled bool
+	Rate          int
+	MaxRate       int
+	BufferFree    int
+	BufferSize    int
+	Battery       int
+	Temperature   int
+	Serial        string
+	Model         string
+}
+
+func (i Info) String() string {
+	return fmt.Sprintf("%v %v (%v) firmware %v", i.Model, i.Serial, i.Addr, i.Firmware)
+}
+
+// parseInfo reads the reply to CmdGetFullInfo
+func parseInfo(b []byte, from *net.UDPAddr) (Info, bool) {
+	if len(b) < infoSize || b[0] != CmdGetFullInfo {
+		return Info{}, false
+	}
+	return Info{
+		Addr:          from,
+		Firmware:      fmt.Sprintf("%d.%d", b[3], b[4]),
+		OutputEnabled: b[5]&1 != 0,
+		Rate:          int(binary.LittleEndian.Uint32(b[10:14])),
+		MaxRate:       int(binary.LittleEndian.Uint32(b[14:18])),
+		BufferFree:    int(binary.LittleEndian.Uint16(b[19:21])),
+		BufferSize:    int(binary.LittleEndian.Uint16(b[21:23])),
+		Battery:       int(b[23]),
+		Temperature:   int(b[24]),
+		Serial:        fmt.Sprintf("%x", b[26:32]),
+		Model:         string(bytes.TrimRight(b[infoSize:], "\x00")),
+	}, true
+}
+
+// Discover broadcasts for LaserCubes on the LAN and returns the ones
+// that answer within timeout
+func Discover(timeout time.Duration) ([]Info, error) {
+	return Scan(&net.UDPAddr{IP: net.IPv4bcast, Port: CmdPort}, timeout)
+}
+
+// Scan asks addr, a broadcast or a single LaserCube, for its info and
+// returns the ones that answer within timeout
+func Scan(addr *net.UDPAddr, timeout time.Duration) ([]Info, error) {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.WriteToUDP([]byte{CmdGetFullInfo}, addr); err != nil {
+		return nil, err
+	}
+
+	var ret []Info
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	b := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFromUDP(b)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return ret, nil
+			}
+			return ret, err
+		}
+		if info, ok := parseInfo(b[:n], from); ok {
+			ret = append(ret, info)
+		}
+	}
+}
+
+// sample converts a point to the LaserCube's 12 bit unsigned X, Y and
+// colors
+func sample(b []byte, p etherdream.Point) {
+	binary.LittleEndian.PutUint16(b[0:2], uint16(int(p.X)+32768)>>4)
+	binary.LittleEndian.PutUint16(b[2:4], uint16(int(p.Y)+32768)>>4)
+	binary.LittleEndian.PutUint16(b[4:6], p.R>>4)
+	binary.LittleEndian.PutUint16(b[6:8], p.G>>4)
+	binary.LittleEndian.PutUint16(b[8:10], p.B>>4)
+}
+
+// Output streams points to a LaserCube, an etherdream.Output. The cube
+// reports the free space in its buffer after data packets and samples
+// are only sent when there is room for them. A frame with a Rate sets
+// the cube's rate as it is sent, the cube has no way to queue it, so
+// points still buffered play at the new rate too.
+type Output struct {
+	Info Info
+	// ScanRate in points per second until a frame sets its Rate, the
+	// -scan-rate flag when 0. Set it before the first frame.
+	ScanRate int
+
+	cmd, data *net.UDPConn
+	mu        sync.Mutex
+	free      int
+	freeAt    time.Time
+	polled    time.Time
+	pollSent  uint32
+	pollOpen  bool
+	sent      int
+	msg       byte
+	frame     byte
+	points    uint32
+	playing   int
+	started   bool
+	closed    bool
+}
+
+// Dial connects to the LaserCube at host, asks for its info and turns
+// on buffer replies
+func Dial(host string) (*Output, error) {
+	ip, err := net.ResolveIPAddr("ip4", host)
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: ip.IP, Port: CmdPort})
+	if err != nil {
+		return nil, err
+	}
+	data, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: ip.IP, Port: DataPort})
+	if err != nil {
+		cmd.Close()
+		return nil, err
+	}
+	o := &Output{cmd: cmd, data: data}
+
+	// ask a few times, UDP gets lost
+	b := make([]byte, 1500)
+	for try := 0; try < 3 && o.Info.Addr == nil; try++ {
+		cmd.Write([]byte{CmdGetFullInfo})
+		cmd.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+		for {
+			n, err := cmd.Read(b)
+			if err != nil {
+				break
+			}
+			if info, ok := parseInfo(b[:n], cmd.RemoteAddr().(*net.UDPAddr)); ok {
+				o.Info = info
+				break
+			}
+		}
+	}
+	cmd.SetReadDeadline(time.Time{})
+	if o.Info.Addr == nil {
+		o.close()
+		return nil, fmt.Errorf("lasercube: no reply from %v", host)
+	}
+	if o.Info.BufferSize == 0 {
+		// older firmware leaves it out
+		o.Info.BufferSize = 6000
+	}
+	o.free, o.freeAt = o.Info.BufferFree, time.Now()
+
+	if _, err := cmd.Write([]byte{CmdEnableBufferResponse, 1}); err != nil {
+		o.close()
+		return nil, err
+	}
+	go o.replies()
+	return o, nil
+}
+
+// replies reads the buffer free space the cube sends after data, and
+// in answer to polls
+func (o *Output) replies() {
+	b := make([]byte, 1500)
+	for {
+		n, err := o.data.Read(b)
+		if err != nil {
+			return
+		}
+		if n >= 4 && b[0] == CmdGetBufferFree {
+			o.mu.Lock()
+			o.free, o.freeAt, o.sent = int(binary.LittleEndian.Uint16(b[2:4])), time.Now(), 0
+			if o.pollOpen {
+				// the answer to a poll doesn't cover the points
+				// sent after it went out
+				o.sent = int(o.points - o.pollSent)
+				o.pollOpen = false
+			}
+			o.mu.Unlock()
+		}
+	}
+}
+
+// rate is the rate the cube is playing at
+func (o *Output) rate() int {
+	if o.playing > 0 {
+		return o.playing
+	}
+	if o.ScanRate > 0 {
+		return o.ScanRate
+	}
+	return *etherdream.ScanRate
+}
+
+// room estimates the free space in the cube's buffer, from the last
+// reply less what has been sent since and plus what has played
+func (o *Output) room() int {
+	played := int(time.Since(o.freeAt).Seconds() * float64(o.rate()))
+	return min(o.Info.BufferSize, o.free-o.sent+played)
+}
+
+// setRate changes the rate the cube plays at
+func (o *Output) setRate(rate int) error {
+	b := make([]byte, 5)
+	b[0] = CmdSetRate
+	binary.LittleEndian.PutUint32(b[1:], uint32(rate))
+	if _, err := o.cmd.Write(b); err != nil {
+		return err
+	}
+	// what has played so far went at the old rate
+	o.free, o.freeAt, o.sent = o.room(), time.Now(), 0
+	o.playing = rate
+	return nil
+}
+
+// start sets the rate and turns the output on
+func (o *Output) start(rate int) error {
+	if err := o.setRate(rate); err != nil {
+		return err
+	}
+	_, err := o.cmd.Write([]byte{CmdSetOutput, 1})
+	return err
+}
+
+// WriteFrame sends the points of f as buffer space comes free
+func (o *Output) WriteFrame(ctx context.Context, f etherdream.Frame) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return net.ErrClosed
+	}
+	rate := o.rate()
+	if f.Rate > 0 {
+		rate = f.Rate
+	}
+	if !o.started {
+		if err := o.start(rate); err != nil {
+			return err
+		}
+		o.started = true
+	} else if rate != o.playing {
+		if err := o.setRate(rate); err != nil {
+			return err
+		}
+	}
+
+	pending := f.Points
+	for len(pending) > 0 {
+		n := min(len(pending), samplesPerPacket)
+		// a packet of margin for replies still on the way
+		if o.room() < n+samplesPerPacket {
+			o.mu.Unlock()
+			select {
+			case <-time.After(time.Millisecond):
+			case <-ctx.Done():
+				o.mu.Lock()
+				return ctx.Err()
+			}
+			o.mu.Lock()
+			if o.closed {
+				return net.ErrClosed
+			}
+			if time.Since(o.freeAt) > 100*time.Millisecond && time.Since(o.polled) > pollInterval {
+				// no replies lately, ask on the data socket so the
+				// answer reaches replies()
+				o.data.Write([]byte{CmdGetBufferFree})
+				o.polled = time.Now()
+				o.pollSent, o.pollOpen = o.points, true
+			}
+			continue
+		}
+
+		b := make([]byte, 4+n*sampleSize)
+		b[0], b[2], b[3] = CmdSampleData, o.msg, o.frame
+		for iX, p := range pending[:n] {
+			sample(b[4+iX*sampleSize:], p)
+		}
+		if _, err := o.data.Write(b); err != nil {
+			return err
+		}
+		o.msg++
+		o.sent += n
+		o.points += uint32(n)
+		pending = pending[n:]
+	}
+	o.frame++
+	return nil
+}
+
+// Status reports the estimated buffer, the rate playing and the points
+// sent
+func (o *Output) Status() etherdream.DACStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	st := etherdream.DACStatus{
+		PointRate:  uint32(o.rate()),
+		PointCount: o.points,
+	}
+	if o.started && !o.closed {
+		st.PlaybackState = etherdream.PlaybackPlaying
+		st.BufferFullness = uint16(max(0, o.Info.BufferSize-o.room()))
+	}
+	return st
+}
+
+// Close turns the output off and closes the sockets
+func (o *Output) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	o.cmd.Write([]byte{CmdSetOutput, 0})
+	return o.close()
+}
+
+func (o *Output) close() error {
+	o.data.Close()
+	return o.cmd.Close()
+}
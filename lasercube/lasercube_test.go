@@ -0,0 +1,230 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package lasercube
+
+import (
+	"context"
+	"encoding/binary"
+	"image/color"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tgreiser/etherdream"
+)
+
+func TestSample(t *testing.T) {
+	b := make([]byte, sampleSize)
+	for _, c := range []struct {
+		p                etherdream.Point
+		x, y, r, g, blue uint16
+	}{
+		{etherdream.Point{X: -32768, Y: 32767}, 0, 0xfff, 0, 0, 0},
+		{etherdream.Point{X: 0, Y: 0, R: 0xffff, G: 0x8000, B: 0x000f}, 0x800, 0x800, 0xfff, 0x800, 0},
+	} {
+		sample(b, c.p)
+		got := []uint16{
+			binary.LittleEndian.Uint16(b[0:2]), binary.LittleEndian.Uint16(b[2:4]),
+			binary.LittleEndian.Uint16(b[4:6]), binary.LittleEndian.Uint16(b[6:8]), binary.LittleEndian.Uint16(b[8:10]),
+		}
+		want := []uint16{c.x, c.y, c.r, c.g, c.blue}
+		for iX := range got {
+			if got[iX] != want[iX] {
+				t.Errorf("sample(%+v) = %x, want %x", c.p, got, want)
+				break
+			}
+		}
+	}
+}
+
+// fakeCube plays samples at rate from a buffer of size, answering info
+// requests on the command port and buffer polls on the data port. Like
+// a cube that lost its buffer replies, it says nothing after data.
+type fakeCube struct {
+	cmd, data *net.UDPConn
+	rate      int
+	size      int
+
+	mu       sync.Mutex
+	buffered float64
+	tick     time.Time
+	maxFill  float64
+	samples  int
+	polls    int
+	// mute stops the poll answers too
+	mute bool
+}
+
+func newFakeCube(t *testing.T) *fakeCube {
+	t.Helper()
+	lo := net.IPv4(127, 0, 0, 1)
+	cmd, err := net.ListenUDP("udp4", &net.UDPAddr{IP: lo, Port: CmdPort})
+	if err != nil {
+		t.Skipf("LaserCube ports in use: %v", err)
+	}
+	data, err := net.ListenUDP("udp4", &net.UDPAddr{IP: lo, Port: DataPort})
+	if err != nil {
+		cmd.Close()
+		t.Skipf("LaserCube ports in use: %v", err)
+	}
+	c := &fakeCube{cmd: cmd, data: data, rate: 30000, size: 6000, tick: time.Now()}
+	t.Cleanup(func() {
+		cmd.Close()
+		data.Close()
+	})
+	go c.serveCmd()
+	go c.serveData()
+	return c
+}
+
+// advance plays the buffer down to now
+func (c *fakeCube) advance() {
+	now := time.Now()
+	c.buffered = max(0, c.buffered-now.Sub(c.tick).Seconds()*float64(c.rate))
+	c.tick = now
+}
+
+func (c *fakeCube) serveCmd() {
+	b := make([]byte, 1500)
+	for {
+		n, from, err := c.cmd.ReadFromUDP(b)
+		if err != nil {
+			return
+		}
+		if n > 0 && b[0] == CmdGetFullInfo {
+			info := make([]byte, infoSize+8)
+			info[0], info[3], info[4] = CmdGetFullInfo, 0, 17
+			binary.LittleEndian.PutUint32(info[10:14], uint32(c.rate))
+			binary.LittleEndian.PutUint32(info[14:18], 40000)
+			binary.LittleEndian.PutUint16(info[19:21], uint16(c.size))
+			binary.LittleEndian.PutUint16(info[21:23], uint16(c.size))
+			copy(info[infoSize:], "fake")
+			c.cmd.WriteToUDP(info, from)
+		}
+	}
+}
+
+func (c *fakeCube) serveData() {
+	b := make([]byte, 1500)
+	for {
+		n, from, err := c.data.ReadFromUDP(b)
+		if err != nil {
+			return
+		}
+		c.mu.Lock()
+		c.advance()
+		switch {
+		case n >= 4 && b[0] == CmdSampleData:
+			c.buffered += float64((n - 4) / sampleSize)
+			c.samples += (n - 4) / sampleSize
+			c.maxFill = max(c.maxFill, c.buffered)
+		case n >= 1 && b[0] == CmdGetBufferFree:
+			c.polls++
+			if c.mute {
+				break
+			}
+			free := make([]byte, 4)
+			free[0] = CmdGetBufferFree
+			binary.LittleEndian.PutUint16(free[2:4], uint16(c.size-int(c.buffered)))
+			c.data.WriteToUDP(free, from)
+		}
+		c.mu.Unlock()
+	}
+}
+
+func TestOutputPollsBuffer(t *testing.T) {
+	cube := newFakeCube(t)
+	o, err := Dial("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if o.Info.Model != "fake" || o.Info.BufferSize != 6000 {
+		t.Errorf("info %+v", o.Info)
+	}
+	o.ScanRate = cube.rate
+
+	pts := make([]etherdream.Point, 1000)
+	for iX := range pts {
+		pts[iX] = *etherdream.NewPoint(iX, iX, color.White)
+	}
+	ctx := context.Background()
+	start := time.Now()
+	for iX := 0; iX < 15; iX++ {
+		if err := o.WriteFrame(ctx, etherdream.Frame{Points: pts}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	elapsed := time.Since(start)
+	// let the last packet land
+	time.Sleep(20 * time.Millisecond)
+
+	cube.mu.Lock()
+	defer cube.mu.Unlock()
+	if cube.samples != 15*len(pts) {
+		t.Errorf("cube got %v samples, want %v", cube.samples, 15*len(pts))
+	}
+	if cube.maxFill > float64(cube.size) {
+		t.Errorf("cube buffer overfilled to %v of %v", cube.maxFill, cube.size)
+	}
+	if cube.polls == 0 {
+		t.Fatalf("no buffer polls with the cube silent")
+	}
+	if limit := int(elapsed/pollInterval) + 2; cube.polls > limit {
+		t.Errorf("%v buffer polls in %v, want at most %v", cube.polls, elapsed, limit)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// the answers to the polls were read
+	if since := time.Since(o.freeAt); since > 300*time.Millisecond {
+		t.Errorf("last buffer reply %v ago, poll answers aren't read", since)
+	}
+}
+
+func TestOutputPollsMuteCube(t *testing.T) {
+	cube := newFakeCube(t)
+	cube.mu.Lock()
+	cube.mute = true
+	cube.mu.Unlock()
+	o, err := Dial("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	o.ScanRate = cube.rate
+
+	// a cube that never answers still gets polled now and then, not
+	// on every pass of the wait
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	pts := make([]etherdream.Point, 1000)
+	for ctx.Err() == nil {
+		o.WriteFrame(ctx, etherdream.Frame{Points: pts})
+	}
+	elapsed := time.Since(start)
+
+	cube.mu.Lock()
+	defer cube.mu.Unlock()
+	if cube.polls == 0 {
+		t.Errorf("no buffer polls with the cube silent")
+	}
+	if limit := int(elapsed/pollInterval) + 2; cube.polls > limit {
+		t.Errorf("%v buffer polls in %v, want at most %v", cube.polls, elapsed, limit)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// every connected DAC, for expvar and the metrics handler
+var registry = struct {
+	sync.Mutex
+	dacs []*DAC
+}{}
+
+func register(d *DAC) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.dacs = append(registry.dacs, d)
+}
+
+func unregister(d *DAC) {
+	registry.Lock()
+	defer registry.Unlock()
+	for iX, r := range registry.dacs {
+		if r == d {
+			registry.dacs = append(registry.dacs[:iX], registry.dacs[iX+1:]...)
+			return
+		}
+	}
+}
+
+// AllHealth returns a Health snapshot for every connected DAC
+func AllHealth() []Health {
+	registry.Lock()
+	dacs := append([]*DAC(nil), registry.dacs...)
+	registry.Unlock()
+
+	ret := make([]Health, len(dacs))
+	for iX, d := range dacs {
+		ret[iX] = d.Health()
+	}
+	return ret
+}
+
+func init() {
+	// shows up under "etherdream" in /debug/vars
+	expvar.Publish("etherdream", expvar.Func(func() interface{} {
+		ret := map[string]Health{}
+		for _, h := range AllHealth() {
+			ret[h.DAC] = h
+		}
+		return ret
+	}))
+}
+
+// MetricsHandler serves the health of every connected DAC in the
+// Prometheus text exposition format.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		WriteMetrics(w, AllHealth())
+	})
+}
+
+// WriteMetrics writes hs in the Prometheus text exposition format
+func WriteMetrics(w io.Writer, hs []Health) {
+	gauge := func(name, help string, v func(h Health) float64) {
+		fmt.Fprintf(w, "# HELP etherdream_%s %s\n# TYPE etherdream_%s gauge\n", name, help, name)
+		for _, h := range hs {
+			fmt.Fprintf(w, "etherdream_%s{dac=\"%s\"} %g\n", name, escapeLabel(h.DAC), v(h))
+		}
+	}
+	counter := func(name, help string, v func(h Health) int) {
+		fmt.Fprintf(w, "# HELP etherdream_%s %s\n# TYPE etherdream_%s counter\n", name, help, name)
+		for _, h := range hs {
+			fmt.Fprintf(w, "etherdream_%s{dac=\"%s\"} %d\n", name, escapeLabel(h.DAC), v(h))
+		}
+	}
+
+	gauge("buffer_fullness_avg", "Average buffer fullness in points while playing, over the status history.",
+		func(h Health) float64 { return h.AvgBufferFullness })
+	gauge("buffer_fullness_min", "Minimum buffer fullness in points while playing, over the status history.",
+		func(h Health) float64 { return float64(h.MinBufferFullness) })
+	gauge("buffer_fullness", "Buffer fullness in points from the last status.",
+		func(h Health) float64 { return float64(h.LastStatus.BufferFullness) })
+	gauge("point_rate_requested", "Point rate requested with the begin command.",
+		func(h Health) float64 { return float64(h.RequestedPointRate) })
+	gauge("point_rate_actual", "Point rate measured from the status history.",
+		func(h Health) float64 { return h.ActualPointRate })
+	gauge("playback_state", "Playback state: 0 idle, 1 prepared, 2 playing.",
+		func(h Health) float64 { return float64(h.LastStatus.PlaybackState) })
+	gauge("light_engine_state", "Light engine state: 0 ready, 1 warmup, 2 cooldown, 3 e-stop.",
+		func(h Health) float64 { return float64(h.LastStatus.LightEngineState) })
+
+	fmt.Fprintf(w, "# HELP etherdream_ack_latency_seconds Round trip time of command replies.\n# TYPE etherdream_ack_latency_seconds summary\n")
+	for _, h := range hs {
+		dac := escapeLabel(h.DAC)
+		l := h.AckLatency
+		fmt.Fprintf(w, "etherdream_ack_latency_seconds{dac=\"%s\",quantile=\"0.5\"} %g\n", dac, l.P50.Seconds())
+		fmt.Fprintf(w, "etherdream_ack_latency_seconds{dac=\"%s\",quantile=\"0.9\"} %g\n", dac, l.P90.Seconds())
+		fmt.Fprintf(w, "etherdream_ack_latency_seconds{dac=\"%s\",quantile=\"0.99\"} %g\n", dac, l.P99.Seconds())
+		fmt.Fprintf(w, "etherdream_ack_latency_seconds_sum{dac=\"%s\"} %g\n", dac, h.AckTotal.Seconds())
+		fmt.Fprintf(w, "etherdream_ack_latency_seconds_count{dac=\"%s\"} %d\n", dac, h.Acks)
+	}
+
+	counter("points_played_total", "Points sent to the DAC.",
+		func(h Health) int { return h.PointsPlayed })
+	counter("underflows_total", "Buffer underflows reported by the DAC.",
+		func(h Health) int { return h.Underflows })
+	counter("naks_total", "Commands answered with a NAK.",
+		func(h Health) int { return h.NAKs })
+	counter("reconnects_total", "Reconnects after a dropped connection.",
+		func(h Health) int { return h.Reconnects })
+	counter("estops_total", "Times the light engine entered E-Stop.",
+		func(h Health) int { return h.EStops })
+}
+
+func escapeLabel(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return strings.Replace(s, "\n", `\n`, -1)
+}
@@ -0,0 +1,245 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tgreiser/ln/ln"
+)
+
+// OptimizeOptions control OptimizePaths
+type OptimizeOptions struct {
+	// JoinLoops lets closed paths start at whichever vertex is closest
+	// to the path before
+	JoinLoops bool
+	// Passes limits the 2-opt passes, 0 runs until nothing improves
+	Passes int
+	// DrawSpeed is passed to DrawPaths when timing the frame, 0 uses
+	// the -draw-speed flag
+	DrawSpeed float64
+}
+
+// PathStats describe how a set of paths will draw
+type PathStats struct {
+	// BlankDistance is the total length of the blank moves, including
+	// the move back to the start of the frame
+	BlankDistance float64
+	// Blanks is the number of blank moves
+	Blanks int
+	// Points is the number of points DrawPaths writes
+	Points int
+	// DrawTime is how long the points take at the scan rate
+	DrawTime time.Duration
+}
+
+func (s PathStats) String() string {
+	return fmt.Sprintf("%v points in %v, %v blank moves over %.0f", s.Points, s.DrawTime, s.Blanks, s.BlankDistance)
+}
+
+// OptimizeReport compares the paths before and after OptimizePaths
+type OptimizeReport struct {
+	Before PathStats
+	After  PathStats
+}
+
+func (r OptimizeReport) String() string {
+	return fmt.Sprintf("before: %v\nafter:  %v", r.Before, r.After)
+}
+
+// MeasurePaths counts the points and blank moves DrawPaths will make
+// for paths
+func MeasurePaths(paths ColorPaths, drawSpeed float64) PathStats {
+	if drawSpeed == 0.0 {
+		drawSpeed = *DrawSpeed
+	}
+	var st PathStats
+	for iX, cp := range paths {
+		if len(cp.Path) == 0 {
+			continue
+		}
+		for jX := 0; jX+1 < len(cp.Path); jX++ {
+			// DrawPath writes ceil(segments) points then the end point
+			fn := NumberOfSegments(ln.Path{cp.Path[jX], cp.Path[jX+1]}, drawSpeed)
+			st.Points += int(math.Ceil(fn)) + 1
+		}
+		from := cp.Path[len(cp.Path)-1]
+		next := paths[(iX+1)%len(paths)].Path
+		if len(next) > 0 {
+			if d := from.Distance(next[0]); d > 0 {
+				st.Blanks++
+				st.BlankDistance += d
+				st.Points += *BlankCount
+			}
+		}
+	}
+	if *ScanRate > 0 {
+		st.DrawTime = time.Duration(st.Points) * time.Second / time.Duration(*ScanRate)
+	}
+	return st
+}
+
+// OptimizePaths reorders and reverses paths to cut down the blank moves
+// between them. Paths are chained nearest neighbour first, then 2-opt
+// swaps are made while they shorten the blank travel. The frame is
+// treated as a loop, so the move from the last path back to the first
+// counts too. The paths are not modified, a new slice is returned.
+func OptimizePaths(paths ColorPaths, opts OptimizeOptions) (ColorPaths, OptimizeReport) {
+	rep := OptimizeReport{Before: MeasurePaths(paths, opts.DrawSpeed)}
+
+	tour := make(ColorPaths, 0, len(paths))
+	for _, cp := range paths {
+		if len(cp.Path) > 0 {
+			tour = append(tour, cp)
+		}
+	}
+	if len(tour) > 1 {
+		tour = nearestNeighbour(tour, opts.JoinLoops)
+		twoOpt(tour, opts.Passes)
+		if opts.JoinLoops {
+			rotateLoops(tour)
+		}
+	}
+
+	rep.After = MeasurePaths(tour, opts.DrawSpeed)
+	return tour, rep
+}
+
+// isLoop is true for paths that end where they start
+func isLoop(p ln.Path) bool {
+	return len(p) > 2 && p[0].Distance(p[len(p)-1]) < 1
+}
+
+// reversed returns the path drawn the other way
+func reversed(cp ColorPath) ColorPath {
+	p := make(ln.Path, len(cp.Path))
+	for iX, v := range cp.Path {
+		p[len(p)-1-iX] = v
+	}
+	return ColorPath{Path: p, Color: cp.Color}
+}
+
+// rotated starts a closed path at vertex i
+func rotated(cp ColorPath, i int) ColorPath {
+	// drop the closing vertex, rotate, then close again
+	open := cp.Path[:len(cp.Path)-1]
+	p := make(ln.Path, 0, len(cp.Path))
+	p = append(p, open[i:]...)
+	p = append(p, open[:i]...)
+	p = append(p, open[i])
+	return ColorPath{Path: p, Color: cp.Color}
+}
+
+// nearestNeighbour starts with the first path and keeps jumping to the
+// closest unused end point
+func nearestNeighbour(paths ColorPaths, joinLoops bool) ColorPaths {
+	used := make([]bool, len(paths))
+	tour := make(ColorPaths, 0, len(paths))
+	tour = append(tour, paths[0])
+	used[0] = true
+	at := paths[0].Path[len(paths[0].Path)-1]
+
+	for len(tour) < len(paths) {
+		best, bestD := -1, math.Inf(1)
+		var bestPath ColorPath
+		for iX, cp := range paths {
+			if used[iX] {
+				continue
+			}
+			p := cp.Path
+			if joinLoops && isLoop(p) {
+				for jX := 0; jX < len(p)-1; jX++ {
+					if d := at.Distance(p[jX]); d < bestD {
+						best, bestD = iX, d
+						bestPath = rotated(cp, jX)
+					}
+				}
+				continue
+			}
+			if d := at.Distance(p[0]); d < bestD {
+				best, bestD, bestPath = iX, d, cp
+			}
+			if d := at.Distance(p[len(p)-1]); d < bestD {
+				best, bestD, bestPath = iX, d, reversed(cp)
+			}
+		}
+		used[best] = true
+		tour = append(tour, bestPath)
+		at = bestPath.Path[len(bestPath.Path)-1]
+	}
+	return tour
+}
+
+// twoOpt reverses runs of the tour, and the paths in them, while that
+// shortens the blank moves
+func twoOpt(tour ColorPaths, passes int) {
+	n := len(tour)
+	start := func(i int) ln.Vector { return tour[i%n].Path[0] }
+	end := func(i int) ln.Vector { p := tour[(i+n)%n].Path; return p[len(p)-1] }
+
+	for pass := 0; passes == 0 || pass < passes; pass++ {
+		improved := false
+		for iX := 1; iX < n; iX++ {
+			for jX := iX; jX < n; jX++ {
+				before := end(iX-1).Distance(start(iX)) + end(jX).Distance(start(jX+1))
+				after := end(iX-1).Distance(end(jX)) + start(iX).Distance(start(jX+1))
+				if after < before-1e-9 {
+					reverseRun(tour, iX, jX)
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			return
+		}
+	}
+}
+
+// reverseRun reverses the order of tour[i..j] and each path in it
+func reverseRun(tour ColorPaths, i, j int) {
+	for ; i < j; i, j = i+1, j-1 {
+		tour[i], tour[j] = reversed(tour[j]), reversed(tour[i])
+	}
+	if i == j {
+		tour[i] = reversed(tour[i])
+	}
+}
+
+// rotateLoops moves the start of each closed path to the vertex that
+// makes the shortest moves in and out
+func rotateLoops(tour ColorPaths) {
+	n := len(tour)
+	for iX, cp := range tour {
+		if !isLoop(cp.Path) {
+			continue
+		}
+		prev := tour[(iX+n-1)%n].Path
+		in := prev[len(prev)-1]
+		out := tour[(iX+1)%n].Path[0]
+		best, bestD := 0, math.Inf(1)
+		for jX, v := range cp.Path[:len(cp.Path)-1] {
+			if d := in.Distance(v) + v.Distance(out); d < bestD {
+				best, bestD = jX, d
+			}
+		}
+		if best != 0 {
+			tour[iX] = rotated(cp, best)
+		}
+	}
+}
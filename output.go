@@ -0,0 +1,173 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Output is somewhere frames can be played, a DAC or a stand-in for
+// one like a file or a preview. Play drives any Output from a
+// FrameSource.
+type Output interface {
+	// WriteFrame plays f, blocking until the output has taken it
+	WriteFrame(ctx context.Context, f Frame) error
+	// Status reports the state of the output the way a DAC would
+	Status() DACStatus
+	Close() error
+}
+
+// Play sends frames from src to out until the source returns io.EOF or
+// ctx is done. Any other error from the source or the output is
+// returned.
+func Play(ctx context.Context, out Output, src FrameSource) error {
+	for {
+		f, err := src.NextFrame(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = out.WriteFrame(ctx, f); err != nil {
+			return err
+		}
+	}
+}
+
+// PlayStream plays a PointStream to out, split into frames at each
+// NextFrame
+func PlayStream(ctx context.Context, out Output, stream PointStream) error {
+	src := NewStreamSource(stream)
+	defer src.Close()
+	return Play(ctx, out, src)
+}
+
+// NullOutput throws frames away. With a ScanRate it takes them only as
+// fast as a DAC would play them, a virtual DAC to pace previews and
+// tests. A frame's Rate then changes the pace from that frame on.
+type NullOutput struct {
+	// ScanRate in points per second, 0 takes frames as fast as they
+	// come
+	ScanRate int
+
+	mu     sync.Mutex
+	points uint32
+	rate   int
+	next   time.Time
+}
+
+// NewNullOutput makes a NullOutput paced at scanRate, 0 for no pacing
+func NewNullOutput(scanRate int) *NullOutput {
+	return &NullOutput{ScanRate: scanRate}
+}
+
+// WriteFrame counts the points of f and waits for them to play
+func (n *NullOutput) WriteFrame(ctx context.Context, f Frame) error {
+	n.mu.Lock()
+	n.points += uint32(len(f.Points))
+	if n.ScanRate <= 0 {
+		n.mu.Unlock()
+		return ctx.Err()
+	}
+	if f.Rate > 0 {
+		n.rate = f.Rate
+	}
+	rate := n.rate
+	if rate <= 0 {
+		rate = n.ScanRate
+	}
+	now := time.Now()
+	if n.next.Before(now) {
+		n.next = now
+	}
+	n.next = n.next.Add(time.Duration(len(f.Points)) * time.Second / time.Duration(rate))
+	wait := time.Until(n.next)
+	n.mu.Unlock()
+
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status reports the points taken so far
+func (n *NullOutput) Status() DACStatus {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	rate := n.rate
+	if rate <= 0 {
+		rate = n.ScanRate
+	}
+	return DACStatus{
+		PlaybackState: PlaybackPlaying,
+		PointRate:     uint32(rate),
+		PointCount:    n.points,
+	}
+}
+
+// Close does nothing
+func (n *NullOutput) Close() error {
+	return nil
+}
+
+// tee plays every frame to all of its outputs
+type tee []Output
+
+// Tee fans frames out to several outputs at once. Each frame is written
+// to all of them in parallel and WriteFrame returns once they have all
+// taken it, so the slowest output sets the pace. Status is the first
+// output's.
+func Tee(outs ...Output) Output {
+	return tee(outs)
+}
+
+func (t tee) WriteFrame(ctx context.Context, f Frame) error {
+	errs := make([]error, len(t))
+	var wg sync.WaitGroup
+	for iX, out := range t {
+		wg.Add(1)
+		go func(iX int, out Output) {
+			defer wg.Done()
+			errs[iX] = out.WriteFrame(ctx, f)
+		}(iX, out)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (t tee) Status() DACStatus {
+	if len(t) == 0 {
+		return DACStatus{}
+	}
+	return t[0].Status()
+}
+
+// Close closes every output
+func (t tee) Close() error {
+	errs := make([]error, len(t))
+	for iX, out := range t {
+		errs[iX] = out.Close()
+	}
+	return errors.Join(errs...)
+}
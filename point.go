@@ -43,6 +43,10 @@ type Point struct {
 	Flags uint16
 }
 
+// RateChange in a point's Flags switches the DAC to the next rate
+// queued with QueueRate as the point arrives
+const RateChange = 0x8000
+
 // NewPoint wil instantiate a point from the basic attributes.
 func NewPoint(x, y int, c color.Color) *Point {
 	r, g, b, a := c.RGBA()
@@ -63,6 +67,19 @@ func NewPoint(x, y int, c color.Color) *Point {
 // rest default to zero.
 func (p Point) Encode() []byte {
 	mut.Lock()
+	var enc = make([]byte, PointSize)
+	p.put(enc)
+
+	if *Dump {
+		fmt.Printf("%v\t%v\t%v\t%v\t%v\n", p.X, p.Y, p.R, p.G, p.B)
+	}
+
+	mut.Unlock()
+	return enc
+}
+
+// put writes the 18 byte encoding of the point into enc
+func (p Point) put(enc []byte) {
 	if p.I <= 0 {
 		p.I = p.R
 		if p.G > p.I {
@@ -72,7 +89,6 @@ func (p Point) Encode() []byte {
 			p.I = p.B
 		}
 	}
-	var enc = make([]byte, 18)
 
 	binary.LittleEndian.PutUint16(enc[0:2], p.Flags)
 	// X and Y are actualy int16
@@ -85,13 +101,22 @@ func (p Point) Encode() []byte {
 	binary.LittleEndian.PutUint16(enc[12:14], p.I)
 	binary.LittleEndian.PutUint16(enc[14:16], p.U1)
 	binary.LittleEndian.PutUint16(enc[16:18], p.U2)
+}
 
-	if *Dump {
-		fmt.Printf("%v\t%v\t%v\t%v\t%v\n", p.X, p.Y, p.R, p.G, p.B)
+// DecodePoint reads a Point back out of the 18 byte wire format
+// produced by Encode.
+func DecodePoint(b []byte) Point {
+	return Point{
+		Flags: binary.LittleEndian.Uint16(b[0:2]),
+		X:     int16(binary.LittleEndian.Uint16(b[2:4])),
+		Y:     int16(binary.LittleEndian.Uint16(b[4:6])),
+		R:     binary.LittleEndian.Uint16(b[6:8]),
+		G:     binary.LittleEndian.Uint16(b[8:10]),
+		B:     binary.LittleEndian.Uint16(b[10:12]),
+		I:     binary.LittleEndian.Uint16(b[12:14]),
+		U1:    binary.LittleEndian.Uint16(b[14:16]),
+		U2:    binary.LittleEndian.Uint16(b[16:18]),
 	}
-
-	mut.Unlock()
-	return enc
 }
 
 func (p Point) ToVector() ln.Vector {
@@ -0,0 +1,168 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package preview
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// Export controls GIF and image sequence export. Frames are timed by
+// their point count at the scan rate, as the DAC would play them.
+type Export struct {
+	Options
+	// Duration of stream to export, 5 seconds when 0
+	Duration time.Duration
+	// FPS of a PNG sequence, 30 when 0
+	FPS int
+	// ScanRate in points per second, the -scan-rate flag when 0
+	ScanRate int
+}
+
+func (ex Export) defaults() Export {
+	if ex.Duration == 0 {
+		ex.Duration = 5 * time.Second
+	}
+	if ex.FPS == 0 {
+		ex.FPS = 30
+	}
+	if ex.ScanRate == 0 {
+		ex.ScanRate = *etherdream.ScanRate
+	}
+	return ex
+}
+
+// frameTime is how long the DAC takes to play f
+func (ex Export) frameTime(f etherdream.Frame) time.Duration {
+	return time.Duration(len(f.Points)) * time.Second / time.Duration(ex.ScanRate)
+}
+
+// WriteGIF renders Duration of src as an animated GIF. Each frame is
+// shown for as long as it plays. GIF delays are in hundredths of a
+// second, so the rounding is carried from frame to frame. A frame too
+// short to show is dropped and its time added to the next one shown.
+// An empty frame is held for one hundredth.
+func WriteGIF(ctx context.Context, w io.Writer, src etherdream.FrameSource, ex Export) error {
+	ex = ex.defaults()
+	anim := &gif.GIF{}
+	var at time.Duration
+	shown := 0
+	for at < ex.Duration {
+		f, err := src.NextFrame(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		at += ex.frameTime(f)
+		if len(f.Points) == 0 {
+			// an empty frame takes no time, don't spin on it
+			at += 10 * time.Millisecond
+		}
+		delay := int(math.Round(at.Seconds()*100)) - shown
+		if delay <= 0 {
+			continue
+		}
+		shown += delay
+
+		img := Render(f, ex.Options)
+		pal := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(pal, img.Bounds(), img, image.Point{})
+		anim.Image = append(anim.Image, pal)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	if len(anim.Image) == 0 {
+		return fmt.Errorf("preview: no frames to export")
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// SaveGIF renders Duration of src to the named GIF file
+func SaveGIF(ctx context.Context, name string, src etherdream.FrameSource, ex Export) error {
+	out, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := WriteGIF(ctx, out, src, ex); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// SavePNGSequence renders Duration of src as PNG files at a steady FPS,
+// ready for ffmpeg. Each file shows the frame playing at that moment, so
+// frames are repeated or skipped when the stream's frame rate differs.
+// pattern is a Printf format for the file number, like
+// "frame%05d.png". It returns the number of files written.
+func SavePNGSequence(ctx context.Context, src etherdream.FrameSource, pattern string, ex Export) (int, error) {
+	ex = ex.defaults()
+	total := int(ex.Duration.Seconds() * float64(ex.FPS))
+
+	var cur etherdream.Frame
+	var img *image.RGBA
+	var end time.Duration
+	for iX := 0; iX < total; iX++ {
+		at := time.Duration(iX) * time.Second / time.Duration(ex.FPS)
+		for end == 0 || at >= end {
+			f, err := src.NextFrame(ctx)
+			if err == io.EOF {
+				return iX, nil
+			}
+			if err != nil {
+				return iX, err
+			}
+			cur, img = f, nil
+			end += ex.frameTime(f)
+			if len(f.Points) == 0 {
+				// an empty frame takes no time, don't spin on it
+				end += time.Second / time.Duration(ex.FPS)
+			}
+		}
+		if img == nil {
+			img = Render(cur, ex.Options)
+		}
+		if err := savePNGImage(fmt.Sprintf(pattern, iX), img); err != nil {
+			return iX, err
+		}
+	}
+	return total, nil
+}
+
+func savePNGImage(name string, img image.Image) error {
+	out, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(out, img); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,82 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package preview
+
+import (
+	"bytes"
+	"context"
+	"image/gif"
+	"testing"
+	"time"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// exportGIF runs WriteGIF on src, failing if it doesn't return
+func exportGIF(t *testing.T, src etherdream.FrameSource, ex Export) *gif.GIF {
+	t.Helper()
+	var buf bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- WriteGIF(context.Background(), &buf, src, ex)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WriteGIF is still running")
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return anim
+}
+
+func delays(anim *gif.GIF) int {
+	sum := 0
+	for _, d := range anim.Delay {
+		sum += d
+	}
+	return sum
+}
+
+func TestWriteGIFEmptyFrames(t *testing.T) {
+	src := etherdream.FrameSourceFunc(func(ctx context.Context) (etherdream.Frame, error) {
+		return etherdream.Frame{}, nil
+	})
+	ex := Export{Options: Options{Width: 16, Height: 16}, Duration: 100 * time.Millisecond, ScanRate: 24000}
+	anim := exportGIF(t, src, ex)
+	if len(anim.Image) != 10 || delays(anim) != 10 {
+		t.Errorf("%v images over %v hundredths, want 10 over 10", len(anim.Image), delays(anim))
+	}
+}
+
+func TestWriteGIFShortFrames(t *testing.T) {
+	// 1ms frames are too short to show, ten make each GIF frame
+	pts := make([]etherdream.Point, 24)
+	src := etherdream.FrameSourceFunc(func(ctx context.Context) (etherdream.Frame, error) {
+		return etherdream.Frame{Points: pts}, nil
+	})
+	ex := Export{Options: Options{Width: 16, Height: 16}, Duration: 200 * time.Millisecond, ScanRate: 24000}
+	anim := exportGIF(t, src, ex)
+	if len(anim.Image) != 20 || delays(anim) != 20 {
+		t.Errorf("%v images over %v hundredths, want 20 over 20", len(anim.Image), delays(anim))
+	}
+}
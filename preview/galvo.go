@@ -0,0 +1,109 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package preview
+
+import (
+	"math"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// Galvo simulates the scanner mirrors, so previews show the corner
+// rounding and overshoot of a real projector. Each axis is a second
+// order system driven toward the commanded point, one point per tick of
+// the point rate. The mirror position carries over from one frame to
+// the next, use one Galvo per stream.
+type Galvo struct {
+	// Bandwidth is the natural frequency of the mirrors in Hz, 1500
+	// when 0
+	Bandwidth float64
+	// Damping ratio, 1 is critically damped and less overshoots. 0.6
+	// when 0.
+	Damping float64
+	// MaxSlew caps the mirror speed in DAC units per second, 0 for no
+	// limit
+	MaxSlew float64
+	// Rate is the point rate in points per second, the -scan-rate flag
+	// when 0
+	Rate int
+	// Substeps is the number of simulated positions per point, 8 when 0
+	Substeps int
+
+	x, y   float64
+	vx, vy float64
+	primed bool
+}
+
+// NewGalvo simulates mirrors with the given bandwidth and damping
+func NewGalvo(bandwidth, damping float64) *Galvo {
+	return &Galvo{Bandwidth: bandwidth, Damping: damping}
+}
+
+func (g *Galvo) substeps() int {
+	if g.Substeps <= 0 {
+		return 8
+	}
+	return g.Substeps
+}
+
+// Simulate plays a frame through the mirrors. The result has Substeps
+// points for every point in f, where the beam actually was, each in the
+// color of the point being played.
+func (g *Galvo) Simulate(f etherdream.Frame) etherdream.Frame {
+	bw, zeta, rate := g.Bandwidth, g.Damping, g.Rate
+	if bw == 0 {
+		bw = 1500
+	}
+	if zeta == 0 {
+		zeta = 0.6
+	}
+	if rate == 0 {
+		rate = *etherdream.ScanRate
+	}
+	n := g.substeps()
+	wn := 2 * math.Pi * bw
+	dt := 1 / float64(rate*n)
+
+	out := make([]etherdream.Point, 0, len(f.Points)*n)
+	for _, p := range f.Points {
+		if !g.primed {
+			g.x, g.y, g.primed = float64(p.X), float64(p.Y), true
+		}
+		for iX := 0; iX < n; iX++ {
+			g.vx = g.axis(float64(p.X), g.x, g.vx, wn, zeta, dt)
+			g.vy = g.axis(float64(p.Y), g.y, g.vy, wn, zeta, dt)
+			g.x += g.vx * dt
+			g.y += g.vy * dt
+
+			sp := p
+			sp.X = int16(math.Max(-32768, math.Min(32767, math.Round(g.x))))
+			sp.Y = int16(math.Max(-32768, math.Min(32767, math.Round(g.y))))
+			out = append(out, sp)
+		}
+	}
+	return etherdream.Frame{Points: out}
+}
+
+// axis steps the velocity of one mirror toward target
+func (g *Galvo) axis(target, pos, vel, wn, zeta, dt float64) float64 {
+	acc := wn*wn*(target-pos) - 2*zeta*wn*vel
+	vel += acc * dt
+	if g.MaxSlew > 0 {
+		vel = math.Max(-g.MaxSlew, math.Min(g.MaxSlew, vel))
+	}
+	return vel
+}
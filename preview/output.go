@@ -0,0 +1,70 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package preview
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// PNGOutput draws the frames played to it to numbered PNG files, an
+// etherdream.Output
+type PNGOutput struct {
+	// Pattern is a Printf format for the frame number, like
+	// "frame%04d.png"
+	Pattern string
+	Options Options
+	// Every draws only every nth frame, all of them when 0
+	Every int
+
+	frames int
+	points uint32
+}
+
+// NewPNGOutput draws frames to files named by pattern
+func NewPNGOutput(pattern string, opts Options) *PNGOutput {
+	return &PNGOutput{Pattern: pattern, Options: opts}
+}
+
+// WriteFrame draws f if it is one of the frames kept
+func (o *PNGOutput) WriteFrame(ctx context.Context, f etherdream.Frame) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	n := o.frames
+	o.frames++
+	o.points += uint32(len(f.Points))
+	if o.Every > 1 && n%o.Every != 0 {
+		return nil
+	}
+	return SavePNG(fmt.Sprintf(o.Pattern, n), f, o.Options)
+}
+
+// Status reports the points drawn so far
+func (o *PNGOutput) Status() etherdream.DACStatus {
+	return etherdream.DACStatus{
+		PlaybackState: etherdream.PlaybackPlaying,
+		PointCount:    o.points,
+	}
+}
+
+// Close does nothing, each file is written as it is drawn
+func (o *PNGOutput) Close() error {
+	return nil
+}
@@ -0,0 +1,289 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package preview draws frames as images, to see what a PointStream
+// does without a projector. The beam is traced between points with
+// additive blending, so dwell points and overlaps glow brighter as they
+// do on the wall.
+package preview
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// Options for drawing frames
+type Options struct {
+	// Width and Height of the image, 512 when 0
+	Width, Height int
+	// BeamWidth in pixels, 1.5 when 0
+	BeamWidth float64
+	// Glow blurs this many pixels of halo around the beam, 0 for none
+	Glow float64
+	// Exposure scales the brightness of the beam, 1 when 0
+	Exposure float64
+	// ShowBlanking draws the blanked moves in BlankColor
+	ShowBlanking bool
+	// BlankColor defaults to a faint grey
+	BlankColor color.Color
+	// Background is drawn behind the beam, black when nil
+	Background color.Color
+	// Galvo draws where simulated mirrors take the beam rather than
+	// the perfect lines between points
+	Galvo *Galvo
+}
+
+func (o Options) defaults() Options {
+	if o.Width == 0 {
+		o.Width = 512
+	}
+	if o.Height == 0 {
+		o.Height = 512
+	}
+	if o.BeamWidth == 0 {
+		o.BeamWidth = 1.5
+	}
+	if o.Exposure == 0 {
+		o.Exposure = 1
+	}
+	if o.BlankColor == nil {
+		o.BlankColor = color.RGBA{0x30, 0x30, 0x30, 0xff}
+	}
+	return o
+}
+
+// canvas accumulates light before it is clipped into an image
+type canvas struct {
+	w, h int
+	buf  []float64
+}
+
+func newCanvas(w, h int) *canvas {
+	return &canvas{w: w, h: h, buf: make([]float64, w*h*3)}
+}
+
+// line adds a segment of light, coverage falls off over the last pixel
+// of the beam's edge. Without add the segment is only drawn where the
+// canvas is darker, so overlaps don't build up.
+func (c *canvas) line(x0, y0, x1, y1, width float64, rgb [3]float64, add bool) {
+	r := width / 2
+	minX := int(math.Floor(math.Min(x0, x1) - r - 1))
+	maxX := int(math.Ceil(math.Max(x0, x1) + r + 1))
+	minY := int(math.Floor(math.Min(y0, y1) - r - 1))
+	maxY := int(math.Ceil(math.Max(y0, y1) + r + 1))
+	minX, minY = max(minX, 0), max(minY, 0)
+	maxX, maxY = min(maxX, c.w-1), min(maxY, c.h-1)
+
+	dx, dy := x1-x0, y1-y0
+	l2 := dx*dx + dy*dy
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+			t := 0.0
+			if l2 > 0 {
+				t = math.Max(0, math.Min(1, ((px-x0)*dx+(py-y0)*dy)/l2))
+			}
+			d := math.Hypot(px-(x0+t*dx), py-(y0+t*dy))
+			cov := math.Max(0, math.Min(1, r-d+0.5))
+			if cov == 0 {
+				continue
+			}
+			iX := (y*c.w + x) * 3
+			for ch := 0; ch < 3; ch++ {
+				if add {
+					c.buf[iX+ch] += rgb[ch] * cov
+				} else {
+					c.buf[iX+ch] = math.Max(c.buf[iX+ch], rgb[ch]*cov)
+				}
+			}
+		}
+	}
+}
+
+// glow adds a blurred copy of the light, three box blurs make a
+// passable gaussian
+func (c *canvas) glow(radius float64) {
+	blur := append([]float64(nil), c.buf...)
+	r := int(math.Max(1, radius/2))
+	tmp := make([]float64, len(blur))
+	for pass := 0; pass < 3; pass++ {
+		boxBlur(blur, tmp, c.w, c.h, r, 1, c.w)
+		boxBlur(tmp, blur, c.h, c.w, r, c.w, 1)
+	}
+	for iX := range c.buf {
+		c.buf[iX] += blur[iX] * 0.6
+	}
+}
+
+// boxBlur averages along lines of n pixels. step moves along a line
+// and stride from one line to the next, both in pixels.
+func boxBlur(src, dst []float64, n, lines, r, step, stride int) {
+	for l := 0; l < lines; l++ {
+		base := l * stride
+		for ch := 0; ch < 3; ch++ {
+			sum := 0.0
+			for iX := -r; iX <= r; iX++ {
+				if iX >= 0 && iX < n {
+					sum += src[(base+iX*step)*3+ch]
+				}
+			}
+			for iX := 0; iX < n; iX++ {
+				dst[(base+iX*step)*3+ch] = sum / float64(2*r+1)
+				if out := iX - r; out >= 0 {
+					sum -= src[(base+out*step)*3+ch]
+				}
+				if in := iX + r + 1; in < n {
+					sum += src[(base+in*step)*3+ch]
+				}
+			}
+		}
+	}
+}
+
+// image clips the light into an image, added to the background
+func (c *canvas) image(bg color.Color) *image.RGBA {
+	var base [3]float64
+	if bg != nil {
+		r, g, b, _ := bg.RGBA()
+		base = [3]float64{float64(r) / etherdream.ColorMax, float64(g) / etherdream.ColorMax, float64(b) / etherdream.ColorMax}
+	}
+	img := image.NewRGBA(image.Rect(0, 0, c.w, c.h))
+	for iX := 0; iX < c.w*c.h; iX++ {
+		for ch := 0; ch < 3; ch++ {
+			img.Pix[iX*4+ch] = uint8(math.Min(255, (base[ch]+c.buf[iX*3+ch])*255))
+		}
+		img.Pix[iX*4+3] = 0xff
+	}
+	return img
+}
+
+// pixel maps DAC coordinates to the image, y up
+func (o Options) pixel(p etherdream.Point) (float64, float64) {
+	x := (float64(p.X) + 32768) / 65536 * float64(o.Width)
+	y := (32768 - float64(p.Y)) / 65536 * float64(o.Height)
+	return x, y
+}
+
+// lit is the beam color of a point, 0 to 1 per channel, scaled by its
+// intensity. An I of 0 is the brightest color, as Encode sends it.
+func lit(p etherdream.Point) [3]float64 {
+	in := p.I
+	if in == 0 {
+		in = max(p.R, p.G, p.B)
+	}
+	i := float64(in) / etherdream.ColorMax
+	return [3]float64{
+		float64(p.R) / etherdream.ColorMax * i,
+		float64(p.G) / etherdream.ColorMax * i,
+		float64(p.B) / etherdream.ColorMax * i,
+	}
+}
+
+// Render draws a frame. Each move takes the color of the point it moves
+// to, as the DAC plays it.
+func Render(f etherdream.Frame, opts Options) *image.RGBA {
+	opts = opts.defaults()
+	c := newCanvas(opts.Width, opts.Height)
+
+	// the beam is thinner than a pixel per point, so scale the light
+	// down to keep slow lines from burning out
+	gain := 0.35 * opts.Exposure
+	if opts.Galvo != nil {
+		f = opts.Galvo.Simulate(f)
+		// the same light is spread over the substeps
+		gain /= float64(opts.Galvo.substeps())
+	}
+	br, bg, bb, _ := opts.BlankColor.RGBA()
+	blank := [3]float64{float64(br) / etherdream.ColorMax, float64(bg) / etherdream.ColorMax, float64(bb) / etherdream.ColorMax}
+
+	for iX, p := range f.Points {
+		prev := p
+		if iX > 0 {
+			prev = f.Points[iX-1]
+		}
+		x0, y0 := opts.pixel(prev)
+		x1, y1 := opts.pixel(p)
+		rgb := lit(p)
+		if rgb == [3]float64{} {
+			if opts.ShowBlanking && (x0 != x1 || y0 != y1) {
+				c.line(x0, y0, x1, y1, 1, blank, false)
+			}
+			continue
+		}
+		for ch := range rgb {
+			rgb[ch] *= gain
+		}
+		c.line(x0, y0, x1, y1, opts.BeamWidth, rgb, true)
+	}
+	if opts.Glow > 0 {
+		c.glow(opts.Glow)
+	}
+	return c.image(opts.Background)
+}
+
+// WritePNG draws a frame to w as a PNG
+func WritePNG(w io.Writer, f etherdream.Frame, opts Options) error {
+	return png.Encode(w, Render(f, opts))
+}
+
+// SavePNG draws a frame to the named PNG file
+func SavePNG(name string, f etherdream.Frame, opts Options) error {
+	out, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := WritePNG(out, f, opts); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// SaveFrames draws up to n frames from src to PNG files. pattern is a
+// Printf format for the frame number, like "frame%04d.png". It stops
+// early if the source ends and returns the number of files written.
+func SaveFrames(ctx context.Context, src etherdream.FrameSource, n int, pattern string, opts Options) (int, error) {
+	for iX := 0; iX < n; iX++ {
+		f, err := src.NextFrame(ctx)
+		if err == io.EOF {
+			return iX, nil
+		}
+		if err != nil {
+			return iX, err
+		}
+		if err := SavePNG(fmt.Sprintf(pattern, iX), f, opts); err != nil {
+			return iX, err
+		}
+	}
+	return n, nil
+}
+
+// SaveStream draws the first n frames of a PointStream to PNG files.
+// Frames are split at NextFrame, or every FramePoints() points for
+// streams that don't call it.
+func SaveStream(stream etherdream.PointStream, n int, pattern string, opts Options) (int, error) {
+	src := etherdream.NewStreamSource(stream)
+	defer src.Close()
+	return SaveFrames(context.Background(), src, n, pattern, opts)
+}
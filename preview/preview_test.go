@@ -0,0 +1,61 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package preview
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tgreiser/etherdream"
+)
+
+func TestLitDefaultsIntensity(t *testing.T) {
+	// a literal with no I is as bright as its brightest color
+	if got := lit(etherdream.Point{R: 0xffff}); got != [3]float64{1, 0, 0} {
+		t.Errorf("lit without I = %v, want full red", got)
+	}
+	if got := lit(etherdream.Point{G: 0xffff, I: 0x8000}); got[1] < 0.49 || got[1] > 0.51 {
+		t.Errorf("lit at half intensity = %v, want half green", got)
+	}
+	if got := lit(etherdream.Point{}); got != [3]float64{} {
+		t.Errorf("lit blank = %v, want black", got)
+	}
+}
+
+func TestRenderPointLiteral(t *testing.T) {
+	f := etherdream.Frame{Points: []etherdream.Point{
+		{X: -10000, Y: 0, G: 0xffff},
+		{X: 10000, Y: 0, G: 0xffff},
+	}}
+	img := Render(f, Options{Width: 64, Height: 64})
+	c := img.RGBAAt(32, 32)
+	if c.G == 0 {
+		t.Errorf("line through the center is %v, want green", c)
+	}
+}
+
+func TestTerminalStatus(t *testing.T) {
+	var out strings.Builder
+	term := NewTerminal(&out)
+	term.DAC = etherdream.NewNullOutput(30000)
+	if err := term.Show(etherdream.Frame{Points: make([]etherdream.Point, 4)}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "4 points  100% blank  buffer 0  30000 pps") {
+		t.Errorf("status line %q, want the output's rate", out.String()[strings.LastIndex(out.String(), "\x1b[K"):])
+	}
+}
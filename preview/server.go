@@ -0,0 +1,280 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package preview
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// Server shows frames live in a browser. It serves a page at / that
+// draws each frame on a canvas, and streams the frames to it as
+// Server-Sent Events from /frames. Feed it with Tee alongside a DAC, or
+// with Play as a virtual output when there is none.
+//
+//	s := preview.NewServer()
+//	go http.ListenAndServe(":8080", s)
+//	err := dac.PlayFrames(ctx, s.Tee(src))
+type Server struct {
+	// MaxFPS caps the frames sent to each browser, 60 when 0. Frames
+	// in between are dropped, the DAC still gets all of them.
+	MaxFPS int
+
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+	sent    time.Time
+}
+
+// NewServer makes a server with no browsers connected
+func NewServer() *Server {
+	return &Server{clients: map[chan []byte]struct{}{}}
+}
+
+// ServeHTTP serves the page and the event stream
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/", "/index.html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, page)
+	case "/frames":
+		s.events(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// events streams frames to one browser until it goes away. A browser
+// that falls behind skips to the newest frame.
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	fl, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fl.Flush()
+
+	ch := make(chan []byte, 1)
+	s.mu.Lock()
+	s.clients[ch] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, ch)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case msg := <-ch:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", msg); err != nil {
+				return
+			}
+			fl.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Clients is the number of browsers watching
+func (s *Server) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// Publish sends a frame to every browser watching. It never blocks on
+// a slow browser.
+func (s *Server) Publish(f etherdream.Frame) {
+	maxFPS := s.MaxFPS
+	if maxFPS <= 0 {
+		maxFPS = 60
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.clients) == 0 || time.Since(s.sent) < time.Second/time.Duration(maxFPS) {
+		return
+	}
+	s.sent = time.Now()
+
+	msg := encodeFrame(f)
+	for ch := range s.clients {
+		// replace a frame the browser hasn't taken yet
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- msg
+	}
+}
+
+// encodeFrame packs a frame as a flat JSON array of x, y, r, g, b for
+// each point, colors 0 to 255 with the intensity applied
+func encodeFrame(f etherdream.Frame) []byte {
+	vals := make([]int, 0, len(f.Points)*5)
+	for _, p := range f.Points {
+		rgb := lit(p)
+		vals = append(vals, int(p.X), int(p.Y), int(rgb[0]*255), int(rgb[1]*255), int(rgb[2]*255))
+	}
+	msg, _ := json.Marshal(vals)
+	return msg
+}
+
+// Tee passes the frames of src through and publishes each one as it
+// is taken, to watch a DAC's output.
+func (s *Server) Tee(src etherdream.FrameSource) etherdream.FrameSource {
+	return etherdream.FrameSourceFunc(func(ctx context.Context) (etherdream.Frame, error) {
+		f, err := src.NextFrame(ctx)
+		if err == nil {
+			s.Publish(f)
+		}
+		return f, err
+	})
+}
+
+// WriteFrame publishes f, making the server an etherdream.Output. Tee
+// it with a DAC, or with a paced NullOutput when there is none.
+func (s *Server) WriteFrame(ctx context.Context, f etherdream.Frame) error {
+	s.Publish(f)
+	return ctx.Err()
+}
+
+// Status has nothing to report, a browser has no buffer
+func (s *Server) Status() etherdream.DACStatus {
+	return etherdream.DACStatus{PlaybackState: etherdream.PlaybackPlaying}
+}
+
+// Close does nothing, browsers stay connected until they go away
+func (s *Server) Close() error {
+	return nil
+}
+
+// Play is a virtual output. It takes frames from src as fast as a DAC
+// at scanRate would, 0 for the -scan-rate flag, and publishes them. It
+// returns nil when the source ends.
+func (s *Server) Play(ctx context.Context, src etherdream.FrameSource, scanRate int) error {
+	return pace(ctx, src, scanRate, func(f etherdream.Frame) error {
+		s.Publish(f)
+		return nil
+	})
+}
+
+// pace takes frames from src as fast as a DAC at scanRate would play
+// them and hands each to fn, until the source ends
+func pace(ctx context.Context, src etherdream.FrameSource, scanRate int, fn func(etherdream.Frame) error) error {
+	if scanRate == 0 {
+		scanRate = *etherdream.ScanRate
+	}
+	next := time.Now()
+	for {
+		f, err := src.NextFrame(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+
+		next = next.Add(time.Duration(len(f.Points)) * time.Second / time.Duration(scanRate))
+		if len(f.Points) == 0 {
+			// don't spin on empty frames
+			next = next.Add(time.Millisecond)
+		}
+		if wait := time.Until(next); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		} else if wait < -time.Second {
+			// fell behind, don't rush to catch up
+			next = time.Now()
+		}
+	}
+}
+
+// page draws each frame as the beam would, lines to each point in its
+// color with additive blending and a fading trail for persistence
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Ether Dream preview</title>
+<style>
+body { margin: 0; background: #000; color: #888; font: 12px sans-serif; }
+canvas { display: block; margin: 0 auto; width: 100vmin; height: 100vmin; }
+#info { position: fixed; top: 4px; left: 6px; }
+</style>
+</head>
+<body>
+<div id="info">connecting</div>
+<canvas id="c" width="1024" height="1024"></canvas>
+<script>
+const c = document.getElementById("c");
+const ctx = c.getContext("2d");
+const info = document.getElementById("info");
+let frames = 0, latest = null;
+
+function px(v) { return (v + 32768) / 65536 * c.width; }
+function py(v) { return (32768 - v) / 65536 * c.height; }
+
+function draw() {
+	requestAnimationFrame(draw);
+	if (!latest) return;
+	const p = latest;
+	latest = null;
+	ctx.globalCompositeOperation = "source-over";
+	ctx.fillStyle = "rgba(0, 0, 0, 0.6)";
+	ctx.fillRect(0, 0, c.width, c.height);
+	ctx.globalCompositeOperation = "lighter";
+	ctx.lineWidth = 2;
+	ctx.lineCap = "round";
+	for (let i = 5; i < p.length; i += 5) {
+		const r = p[i+2], g = p[i+3], b = p[i+4];
+		if (r + g + b == 0) continue;
+		ctx.strokeStyle = "rgb(" + r + "," + g + "," + b + ")";
+		ctx.beginPath();
+		ctx.moveTo(px(p[i-5]), py(p[i-4]));
+		ctx.lineTo(px(p[i]), py(p[i+1]));
+		ctx.stroke();
+	}
+}
+requestAnimationFrame(draw);
+
+const es = new EventSource("frames");
+es.onmessage = (e) => {
+	latest = JSON.parse(e.data);
+	frames++;
+	info.textContent = frames + " frames, " + latest.length / 5 + " points";
+};
+es.onerror = () => { info.textContent = "disconnected, retrying"; };
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,234 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package preview
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// Terminal shows frames in a terminal, for a glance at what is playing
+// over SSH. Each character cell is a 2 by 4 grid of braille dots in
+// 24-bit ANSI color, redrawn in place at a capped rate, with a status
+// line under the picture.
+type Terminal struct {
+	Out io.Writer
+	// Cols and Rows is the size of the terminal, the status line takes
+	// the last row. 80 by 24 when 0.
+	Cols, Rows int
+	// MaxFPS caps the redraws, 10 when 0
+	MaxFPS int
+	// DAC, when set, adds the output's buffer fullness and point rate
+	// to the status line. Any Output will do, a DAC, IDN or LaserCube.
+	DAC etherdream.Output
+
+	mu      sync.Mutex
+	started bool
+	shown   time.Time
+	arrived []time.Time
+}
+
+// NewTerminal draws to out, usually os.Stdout
+func NewTerminal(out io.Writer) *Terminal {
+	return &Terminal{Out: out}
+}
+
+// Show counts a frame and redraws with it if the last redraw was long
+// enough ago
+func (t *Terminal) Show(f etherdream.Frame) error {
+	maxFPS := t.MaxFPS
+	if maxFPS <= 0 {
+		maxFPS = 10
+	}
+	cols, rows := t.Cols, t.Rows
+	if cols <= 0 {
+		cols = 80
+	}
+	if rows <= 0 {
+		rows = 24
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.arrived = append(t.arrived, now)
+	for len(t.arrived) > 0 && now.Sub(t.arrived[0]) > time.Second {
+		t.arrived = t.arrived[1:]
+	}
+	if now.Sub(t.shown) < time.Second/time.Duration(maxFPS) {
+		return nil
+	}
+	t.shown = now
+
+	var b strings.Builder
+	if !t.started {
+		// clear and hide the cursor
+		b.WriteString("\x1b[2J\x1b[?25l")
+		t.started = true
+	}
+	b.WriteString("\x1b[H")
+	b.WriteString(Braille(f, cols, rows-1))
+	b.WriteString("\x1b[0m\x1b[K")
+	b.WriteString(t.status(f))
+	_, err := io.WriteString(t.Out, b.String())
+	return err
+}
+
+// status is the line under the picture
+func (t *Terminal) status(f etherdream.Frame) string {
+	blank := 0
+	for _, p := range f.Points {
+		if lit(p) == [3]float64{} {
+			blank++
+		}
+	}
+	ratio := 0.0
+	if len(f.Points) > 0 {
+		ratio = float64(blank) / float64(len(f.Points))
+	}
+	line := fmt.Sprintf("%3d fps  %5d points  %3.0f%% blank", len(t.arrived), len(f.Points), ratio*100)
+	if t.DAC != nil {
+		st := t.DAC.Status()
+		line += fmt.Sprintf("  buffer %v  %v pps", st.BufferFullness, st.PointRate)
+	}
+	return line
+}
+
+// Close puts the cursor back
+func (t *Terminal) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.started {
+		return nil
+	}
+	_, err := io.WriteString(t.Out, "\x1b[0m\x1b[?25h\n")
+	return err
+}
+
+// Tee passes the frames of src through and shows each one as it is
+// taken, to watch a DAC's output
+func (t *Terminal) Tee(src etherdream.FrameSource) etherdream.FrameSource {
+	return etherdream.FrameSourceFunc(func(ctx context.Context) (etherdream.Frame, error) {
+		f, err := src.NextFrame(ctx)
+		if err == nil {
+			err = t.Show(f)
+		}
+		return f, err
+	})
+}
+
+// WriteFrame shows f, making the terminal an etherdream.Output
+func (t *Terminal) WriteFrame(ctx context.Context, f etherdream.Frame) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return t.Show(f)
+}
+
+// Status is the output's when one is set
+func (t *Terminal) Status() etherdream.DACStatus {
+	if t.DAC != nil {
+		return t.DAC.Status()
+	}
+	return etherdream.DACStatus{PlaybackState: etherdream.PlaybackPlaying}
+}
+
+// Play is a virtual output, it takes frames from src as fast as a DAC
+// at scanRate would, 0 for the -scan-rate flag, and shows them
+func (t *Terminal) Play(ctx context.Context, src etherdream.FrameSource, scanRate int) error {
+	return pace(ctx, src, scanRate, t.Show)
+}
+
+// brailleBits are the dots of a braille cell by column and row
+var brailleBits = [2][4]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+// Braille draws a frame as rows lines of cols braille characters with
+// ANSI colors. Dots are about square in most terminal fonts, so the
+// picture is the largest square that fits, centered.
+func Braille(f etherdream.Frame, cols, rows int) string {
+	size := min(cols*2, rows*4)
+	left := (cols*2 - size) / 2
+	dots := make([]rune, cols*rows)
+	light := make([][3]float64, cols*rows)
+
+	plot := func(x, y float64, rgb [3]float64) {
+		dx, dy := int(x)+left, int(y)
+		if x < 0 || y < 0 || dx >= cols*2 || dy >= rows*4 {
+			return
+		}
+		iX := dy/4*cols + dx/2
+		dots[iX] |= brailleBits[dx%2][dy%4]
+		for ch := range rgb {
+			light[iX][ch] += rgb[ch]
+		}
+	}
+	scale := float64(size) / 65536
+	for iX, p := range f.Points {
+		rgb := lit(p)
+		if rgb == [3]float64{} {
+			continue
+		}
+		prev := p
+		if iX > 0 {
+			prev = f.Points[iX-1]
+		}
+		x0, y0 := (float64(prev.X)+32768)*scale, (32768-float64(prev.Y))*scale
+		x1, y1 := (float64(p.X)+32768)*scale, (32768-float64(p.Y))*scale
+		// a dot at least every half dot along the move
+		n := int(math.Ceil(2 * math.Max(math.Abs(x1-x0), math.Abs(y1-y0))))
+		for iY := 0; iY <= n; iY++ {
+			t := 1.0
+			if n > 0 {
+				t = float64(iY) / float64(n)
+			}
+			plot(x0+(x1-x0)*t, y0+(y1-y0)*t, rgb)
+		}
+	}
+
+	var b strings.Builder
+	last := ""
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			iX := row*cols + col
+			if dots[iX] == 0 {
+				b.WriteByte(' ')
+				continue
+			}
+			// the hue of the light in the cell at full brightness
+			l := light[iX]
+			peak := math.Max(l[0], math.Max(l[1], l[2]))
+			sgr := fmt.Sprintf("\x1b[38;2;%d;%d;%dm", int(l[0]/peak*255), int(l[1]/peak*255), int(l[2]/peak*255))
+			if sgr != last {
+				b.WriteString(sgr)
+				last = sgr
+			}
+			b.WriteRune(0x2800 + dots[iX])
+		}
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,316 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"encoding/json"
+	"image/color"
+	"io"
+	"math"
+	"os"
+
+	"github.com/tgreiser/ln/ln"
+)
+
+// Profile describes how fast a projector's galvos can be driven. Paths
+// drawn with a profile speed up along straight runs, slow down into
+// corners and wait at them so the mirrors can catch up. Keep a JSON
+// file for each projector and read it with LoadProfile.
+type Profile struct {
+	Name string `json:"name"`
+	// MaxStep is the furthest apart points get on a long straight run,
+	// in DAC units
+	MaxStep float64 `json:"max_step"`
+	// MinStep is the spacing at the start and end of a run
+	MinStep float64 `json:"min_step"`
+	// Accel is how much the spacing grows from one point to the next
+	Accel float64 `json:"accel"`
+	// CornerAngle is the smallest turn, in degrees, treated as a
+	// corner. Gentler turns are drawn through at speed.
+	CornerAngle float64 `json:"corner_angle"`
+	// CornerDwell is the number of points held at a full reversal,
+	// smaller corners get a share in proportion to their angle
+	CornerDwell int `json:"corner_dwell"`
+	// EndDwell is the number of points held at each end of a path
+	EndDwell int `json:"end_dwell"`
+
+	// BlankPreDwell is the number of blanked points held at the end of a
+	// path before moving, so the beam is off before the mirrors go
+	BlankPreDwell int `json:"blank_pre_dwell"`
+	// BlankPostDwell is the number of blanked points held at the start
+	// of the next path, so the mirrors settle before the beam comes on
+	BlankPostDwell int `json:"blank_post_dwell"`
+	// BlankDwellScale adds this many points to both dwells for every
+	// 10000 DAC units of the move
+	BlankDwellScale float64 `json:"blank_dwell_scale"`
+	// BlankStep is the average spacing of the points along a blanked
+	// move. 0 jumps straight to the destination.
+	BlankStep float64 `json:"blank_step"`
+	// BlankEase shapes the move, "linear", "sine" or "cubic". The
+	// default sine starts and ends slowly.
+	BlankEase string `json:"blank_ease"`
+}
+
+// DefaultProfile suits a typical 30k galvo set at 24k points per second
+func DefaultProfile() Profile {
+	return Profile{
+		Name:        "default",
+		MaxStep:     200,
+		MinStep:     25,
+		Accel:       15,
+		CornerAngle: 20,
+		CornerDwell: 10,
+		EndDwell:    3,
+	}
+}
+
+// LoadProfile reads a profile from a JSON file. Fields that are left
+// out keep their DefaultProfile values.
+func LoadProfile(name string) (Profile, error) {
+	pr := DefaultProfile()
+	f, err := os.Open(name)
+	if err != nil {
+		return pr, err
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&pr)
+	return pr, err
+}
+
+// turnAngle is the change in direction at b, in degrees
+func turnAngle(a, b, c ln.Vector) float64 {
+	u, v := b.Sub(a), c.Sub(b)
+	lu, lv := u.Length(), v.Length()
+	if lu == 0 || lv == 0 {
+		return 0
+	}
+	cos := math.Max(-1, math.Min(1, u.Dot(v)/(lu*lv)))
+	return math.Acos(cos) * 180 / math.Pi
+}
+
+// cornerDwell is the number of points to hold at a turn of angle degrees
+func (pr Profile) cornerDwell(angle float64) int {
+	if angle < pr.CornerAngle || pr.CornerAngle >= 180 {
+		return 0
+	}
+	return int(math.Round(float64(pr.CornerDwell) * (angle - pr.CornerAngle) / (180 - pr.CornerAngle)))
+}
+
+// PathPoints samples a path for drawing. Points are held at the ends
+// and at each corner, and spaced out along the runs between corners.
+func (pr Profile) PathPoints(p ln.Path, c color.Color) []Point {
+	if len(p) == 0 {
+		return nil
+	}
+	pt := func(v ln.Vector) Point {
+		return *NewPoint(int(v.X), int(v.Y), c)
+	}
+	hold := func(pts []Point, v ln.Vector, n int) []Point {
+		for iX := 0; iX < n; iX++ {
+			pts = append(pts, pt(v))
+		}
+		return pts
+	}
+
+	pts := hold(nil, p[0], 1+pr.EndDwell)
+	loop := len(p) > 2 && p[0] == p[len(p)-1]
+	run := ln.Path{p[0]}
+	for iX := 1; iX < len(p); iX++ {
+		run = append(run, p[iX])
+		dwell := 0
+		switch {
+		case iX+1 < len(p):
+			dwell = pr.cornerDwell(turnAngle(p[iX-1], p[iX], p[iX+1]))
+		case loop:
+			// closing a loop turns into its first segment
+			dwell = pr.cornerDwell(turnAngle(p[iX-1], p[iX], p[1]))
+		}
+		if dwell == 0 && iX+1 < len(p) {
+			continue
+		}
+		for _, d := range pr.runSteps(run) {
+			pts = append(pts, pt(pointAlong(run, d)))
+		}
+		pts = hold(pts, p[iX], dwell)
+		run = ln.Path{p[iX]}
+	}
+	return hold(pts, p[len(p)-1], pr.EndDwell)
+}
+
+// runSteps returns the distances along a run to place points at, not
+// counting the start. Spacing grows by Accel from MinStep up to
+// MaxStep and shrinks again toward the end.
+func (pr Profile) runSteps(run ln.Path) []float64 {
+	length := 0.0
+	for iX := 1; iX < len(run); iX++ {
+		length += run[iX].Distance(run[iX-1])
+	}
+	if length == 0 {
+		return nil
+	}
+	minStep := math.Max(pr.MinStep, 1)
+	maxStep := math.Max(pr.MaxStep, minStep)
+	step := func(k int) float64 {
+		return math.Min(maxStep, minStep+pr.Accel*float64(k))
+	}
+
+	// grow from both ends at once until they meet
+	var front, back []float64
+	df, db := 0.0, 0.0
+	var s float64
+	for {
+		if len(front) <= len(back) {
+			s = step(len(front))
+		} else {
+			s = step(len(back))
+		}
+		if df+db+s >= length {
+			break
+		}
+		if len(front) <= len(back) {
+			df += s
+			front = append(front, df)
+		} else {
+			db += s
+			back = append(back, db)
+		}
+	}
+
+	// stretch the steps so the one where they meet isn't a stub
+	k := length / (df + db + s)
+	ret := make([]float64, 0, len(front)+len(back)+1)
+	for _, d := range front {
+		ret = append(ret, d*k)
+	}
+	for iX := len(back) - 1; iX >= 0; iX-- {
+		ret = append(ret, length-back[iX]*k)
+	}
+	return append(ret, length)
+}
+
+// pointAlong is the point d along path
+func pointAlong(path ln.Path, d float64) ln.Vector {
+	for iX := 1; iX < len(path); iX++ {
+		l := path[iX].Distance(path[iX-1])
+		if d <= l && l > 0 {
+			return path[iX-1].Add(path[iX].Sub(path[iX-1]).MulScalar(d / l))
+		}
+		d -= l
+	}
+	return path[len(path)-1]
+}
+
+// DrawPath draws p with the profile and returns how many points were
+// written and the last one
+func (pr Profile) DrawPath(w io.WriteCloser, p ln.Path, c color.Color) (int, Point) {
+	pts := pr.PathPoints(p, c)
+	for _, pt := range pts {
+		w.Write(pt.Encode())
+	}
+	if len(pts) == 0 {
+		return 0, Point{}
+	}
+	return len(pts), pts[len(pts)-1]
+}
+
+// DrawPaths draws each path with the profile, blanking between paths
+// that don't join up and back to the start at the end. It returns how
+// many points were written and the last one, ready for NextFrame.
+func (pr Profile) DrawPaths(w io.WriteCloser, paths ColorPaths) (int, Point) {
+	pts := pr.framePoints(paths)
+	for _, pt := range pts {
+		w.Write(pt.Encode())
+	}
+	if len(pts) == 0 {
+		return 0, Point{}
+	}
+	return len(pts), pts[len(pts)-1]
+}
+
+// framePoints is every point DrawPaths writes for paths
+func (pr Profile) framePoints(paths ColorPaths) []Point {
+	var pts []Point
+	for iX, cp := range paths {
+		if len(cp.Path) == 0 {
+			continue
+		}
+		pts = append(pts, pr.PathPoints(cp.Path, cp.Color)...)
+		from := cp.Path[len(cp.Path)-1]
+		next := paths[(iX+1)%len(paths)].Path
+		if len(next) > 0 && from.Distance(next[0]) > 0 {
+			pts = append(pts, pr.BlankPoints(from, next[0])...)
+		}
+	}
+	return pts
+}
+
+// BlankPoints are the blanked points for a move between paths: the
+// pre dwell at from, the eased move and the post dwell at to. A profile
+// with none of the blank settings blanks like BlankPath, BlankCount
+// points at the destination.
+func (pr Profile) BlankPoints(from, to ln.Vector) []Point {
+	blank := func(v ln.Vector) Point {
+		return *NewPoint(int(v.X), int(v.Y), BlankColor)
+	}
+	if pr.BlankPreDwell == 0 && pr.BlankPostDwell == 0 && pr.BlankDwellScale == 0 && pr.BlankStep == 0 {
+		pts := make([]Point, *BlankCount)
+		for iX := range pts {
+			pts[iX] = blank(to)
+		}
+		return pts
+	}
+
+	d := from.Distance(to)
+	extra := int(math.Round(pr.BlankDwellScale * d / 10000))
+	travel := 0
+	if pr.BlankStep > 0 {
+		travel = int(math.Ceil(d / pr.BlankStep))
+	}
+	// always finish on the destination
+	post := pr.BlankPostDwell + extra
+	if post < 1 {
+		post = 1
+	}
+
+	pts := make([]Point, 0, pr.BlankPreDwell+extra+travel+post)
+	for iX := 0; iX < pr.BlankPreDwell+extra; iX++ {
+		pts = append(pts, blank(from))
+	}
+	for iX := 1; iX < travel; iX++ {
+		t := ease(pr.BlankEase, float64(iX)/float64(travel))
+		pts = append(pts, blank(from.Add(to.Sub(from).MulScalar(t))))
+	}
+	for iX := 0; iX < post; iX++ {
+		pts = append(pts, blank(to))
+	}
+	return pts
+}
+
+// ease maps t from 0 to 1 onto the named curve
+func ease(name string, t float64) float64 {
+	switch name {
+	case "linear":
+		return t
+	case "cubic":
+		if t < 0.5 {
+			return 4 * t * t * t
+		}
+		u := 2*t - 2
+		return 1 + u*u*u/2
+	}
+	return (1 - math.Cos(math.Pi*t)) / 2
+}
@@ -0,0 +1,434 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ProxyPolicy decides which clients of a Proxy reach the laser
+type ProxyPolicy int
+
+const (
+	// ProxyExclusive plays the first client to start until it stops,
+	// the others wait their turn
+	ProxyExclusive ProxyPolicy = iota
+	// ProxyPriority plays the playing client with the highest
+	// priority, the newest one on a tie
+	ProxyPriority
+	// ProxyZones plays every client at once, each scaled into its own
+	// zone and taking turns within each frame
+	ProxyZones
+)
+
+// ParseProxyPolicy reads "exclusive", "priority" or "zones"
+func ParseProxyPolicy(s string) (ProxyPolicy, bool) {
+	switch s {
+	case "exclusive":
+		return ProxyExclusive, true
+	case "priority":
+		return ProxyPriority, true
+	case "zones":
+		return ProxyZones, true
+	}
+	return 0, false
+}
+
+// Zone is a rectangle of the projection in DAC units
+type Zone struct {
+	MinX, MinY, MaxX, MaxY int
+}
+
+// proxyRelease is how long an exclusive client can stop playing, like
+// an underflow between frames, before another client takes over
+const proxyRelease = 500 * time.Millisecond
+
+// Proxy lets several programs share one DAC. It speaks the Ether Dream
+// protocol to each client as an Emulator would, and is a FrameSource
+// that mixes their points by Policy. Play it to a DAC with PlayFrames.
+//
+//	p, err := etherdream.NewProxy("127.0.0.1:7765", etherdream.ProxyPriority)
+//	err = dac.PlayFrames(ctx, p)
+type Proxy struct {
+	Policy ProxyPolicy
+	// Priorities of ProxyPriority clients, 0 for ones not listed. A key
+	// is a client IP address or a port the proxy listens on, like
+	// ":7766". Programs on one machine share an IP, so give each its
+	// own port with Listen. The port wins when both match.
+	Priorities map[string]int
+	// Zones of ProxyZones clients in the order they started playing,
+	// side by side strips when empty
+	Zones []Zone
+	// Firmware is returned to clients for the 'v' command
+	Firmware string
+	// ScanRate of the DAC the proxy plays to, the -scan-rate flag when
+	// 0. Clients at other rates are resampled.
+	ScanRate int
+
+	lns     []net.Listener
+	mu      sync.Mutex
+	clients []*proxyClient
+	owner   *proxyClient
+	// air is who reached the laser in the last frame
+	air   map[*proxyClient]bool
+	last  Point
+	count int
+}
+
+// proxyClient is one connection and the points it has sent that are
+// yet to be mixed
+type proxyClient struct {
+	addr     net.Addr
+	local    net.Addr
+	n        int
+	emu      *Emulator
+	mu       sync.Mutex
+	queue    []Point
+	last     Point
+	since    time.Time
+	lastSeen time.Time
+}
+
+// ProxyClient describes a connected client
+type ProxyClient struct {
+	Addr     string
+	Priority int
+	Playing  bool
+	Rate     int
+	// Queued is the number of points received and not yet played
+	Queued int
+	// OnAir is true when the client's points are reaching the laser
+	OnAir bool
+}
+
+// NewProxy listens for clients on addr
+func NewProxy(addr string, policy ProxyPolicy) (*Proxy, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	p := &Proxy{
+		Policy:   policy,
+		Firmware: "etherdream proxy",
+		lns:      []net.Listener{ln},
+	}
+	go p.serve(ln)
+	return p, nil
+}
+
+// Listen takes clients on another address too, so they can be told
+// apart by port in Priorities
+func (p *Proxy) Listen(addr string) (*net.TCPAddr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.lns = append(p.lns, ln)
+	p.mu.Unlock()
+	go p.serve(ln)
+	return ln.Addr().(*net.TCPAddr), nil
+}
+
+// Addr is the address NewProxy is listening on
+func (p *Proxy) Addr() *net.TCPAddr {
+	return p.lns[0].Addr().(*net.TCPAddr)
+}
+
+// Port is the listening port, ready for NewDACPort
+func (p *Proxy) Port() string {
+	return strconv.Itoa(p.Addr().Port)
+}
+
+// Close stops listening for clients
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var err error
+	for _, ln := range p.lns {
+		if e := ln.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (p *Proxy) serve(ln net.Listener) {
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		cl := &proxyClient{addr: c.RemoteAddr(), local: c.LocalAddr(), n: p.count}
+		p.count++
+		cl.emu = &Emulator{
+			BufferCapacity: bufferSize,
+			Firmware:       p.Firmware,
+			tick:           time.Now(),
+			onData:         cl.push,
+		}
+		p.clients = append(p.clients, cl)
+		p.mu.Unlock()
+
+		go func() {
+			cl.emu.handle(c)
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			for iX, o := range p.clients {
+				if o == cl {
+					p.clients = append(p.clients[:iX], p.clients[iX+1:]...)
+					break
+				}
+			}
+			if p.owner == cl {
+				p.owner = nil
+			}
+			delete(p.air, cl)
+		}()
+	}
+}
+
+// push queues points from the client, the queue holds no more than an
+// Ether Dream buffer would. The client's rate changes are the proxy's
+// business, they don't go on to the DAC.
+func (cl *proxyClient) push(pts []Point) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	for iX := range pts {
+		pts[iX].Flags &^= RateChange
+	}
+	cl.queue = append(cl.queue, pts...)
+	if over := len(cl.queue) - bufferSize; over > 0 {
+		cl.queue = append(cl.queue[:0], cl.queue[over:]...)
+	}
+}
+
+// playing updates when the client was last playing and reports if it
+// is now
+func (cl *proxyClient) playing(now time.Time) (bool, int) {
+	st := cl.emu.Status()
+	if st.PlaybackState != PlaybackPlaying {
+		return false, 0
+	}
+	if cl.since.IsZero() || now.Sub(cl.lastSeen) > proxyRelease {
+		cl.since = now
+	}
+	cl.lastSeen = now
+	return true, int(st.PointRate)
+}
+
+// take resamples the points the client played in the time n points take
+// at outRate into n points. Missing points hold the last position.
+func (cl *proxyClient) take(n, rate, outRate int) []Point {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	m := n
+	if rate > 0 && outRate > 0 {
+		m = (n*rate + outRate/2) / outRate
+	}
+	src := cl.queue[:min(m, len(cl.queue))]
+	cl.queue = cl.queue[len(src):]
+
+	ret := make([]Point, n)
+	for iX := range ret {
+		if len(src) == 0 {
+			ret[iX] = *NewPoint(int(cl.last.X), int(cl.last.Y), BlankColor)
+			continue
+		}
+		// spread what arrived over the frame
+		ret[iX] = src[iX*len(src)/n]
+	}
+	if len(src) > 0 {
+		cl.last = src[len(src)-1]
+	}
+	return ret
+}
+
+// Clients describes the connected clients, in the order they connected.
+// OnAir is as of the last NextFrame.
+func (p *Proxy) Clients() []ProxyClient {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ret := make([]ProxyClient, len(p.clients))
+	for iX, cl := range p.clients {
+		st := cl.emu.Status()
+		cl.mu.Lock()
+		ret[iX] = ProxyClient{
+			Addr:     cl.addr.String(),
+			Priority: p.priority(cl),
+			Playing:  st.PlaybackState == PlaybackPlaying,
+			Rate:     int(st.PointRate),
+			Queued:   len(cl.queue),
+			OnAir:    p.air[cl],
+		}
+		cl.mu.Unlock()
+	}
+	return ret
+}
+
+func (p *Proxy) priority(cl *proxyClient) int {
+	if p.Priorities == nil {
+		return 0
+	}
+	_, port, _ := net.SplitHostPort(cl.local.String())
+	if n, ok := p.Priorities[":"+port]; ok {
+		return n
+	}
+	host, _, _ := net.SplitHostPort(cl.addr.String())
+	return p.Priorities[host]
+}
+
+// active is a playing client and its point rate
+type active struct {
+	client *proxyClient
+	rate   int
+}
+
+// onAir picks the clients that reach the laser by policy, in the order
+// they started playing. The rest are playing to nobody. It hands the
+// exclusive turn on, so only NextFrame calls it.
+func (p *Proxy) onAir(now time.Time) []active {
+	var playing []active
+	for _, cl := range p.clients {
+		if ok, rate := cl.playing(now); ok {
+			playing = append(playing, active{cl, rate})
+		}
+	}
+	sort.SliceStable(playing, func(i, j int) bool {
+		return playing[i].client.since.Before(playing[j].client.since)
+	})
+	if len(playing) == 0 {
+		return nil
+	}
+
+	switch p.Policy {
+	case ProxyExclusive:
+		if p.owner != nil && now.Sub(p.owner.lastSeen) > proxyRelease {
+			p.owner = nil
+		}
+		for _, a := range playing {
+			if p.owner == nil || a.client == p.owner {
+				p.owner = a.client
+				return []active{a}
+			}
+		}
+		// the owner is between frames, nobody else plays meanwhile
+		return nil
+	case ProxyPriority:
+		best := playing[0]
+		for _, a := range playing[1:] {
+			if p.priority(a.client) > p.priority(best.client) ||
+				p.priority(a.client) == p.priority(best.client) && a.client.n > best.client.n {
+				best = a
+			}
+		}
+		return []active{best}
+	}
+	return playing
+}
+
+// zone is where the ith of n clients draws
+func (p *Proxy) zone(iX, n int) Zone {
+	if len(p.Zones) > 0 {
+		return p.Zones[iX%len(p.Zones)]
+	}
+	w := 65536 / n
+	return Zone{MinX: -32768 + iX*w, MinY: -32768, MaxX: -32768 + (iX+1)*w - 1, MaxY: 32767}
+}
+
+// into scales a point from the full range into z
+func (z Zone) into(pt Point) Point {
+	sx := float64(z.MaxX-z.MinX) / 65535
+	sy := float64(z.MaxY-z.MinY) / 65535
+	pt.X = int16(float64(z.MinX) + (float64(pt.X)+32768)*sx)
+	pt.Y = int16(float64(z.MinY) + (float64(pt.Y)+32768)*sy)
+	return pt
+}
+
+// NextFrame mixes a frame of FramePoints() points from the clients. It
+// never waits, with nobody playing the frame is blank.
+func (p *Proxy) NextFrame(ctx context.Context) (Frame, error) {
+	if err := ctx.Err(); err != nil {
+		return Frame{}, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := FramePoints()
+	outRate := p.ScanRate
+	if outRate <= 0 {
+		outRate = *ScanRate
+	}
+
+	now := time.Now()
+	air := p.onAir(now)
+	p.air = map[*proxyClient]bool{}
+	for _, a := range air {
+		p.air[a.client] = true
+	}
+	// clients off the air still play, to nobody
+	for _, cl := range p.clients {
+		if !p.air[cl] {
+			ok, rate := cl.playing(now)
+			if !ok {
+				cl.mu.Lock()
+				cl.queue = nil
+				cl.mu.Unlock()
+				continue
+			}
+			cl.take(n, rate, outRate)
+		}
+	}
+
+	var pts []Point
+	switch len(air) {
+	case 0:
+		for iX := 0; iX < n; iX++ {
+			pts = append(pts, *NewPoint(int(p.last.X), int(p.last.Y), BlankColor))
+		}
+	case 1:
+		pts = air[0].client.take(n, air[0].rate, outRate)
+	default:
+		// each zone gets a share of the frame, less a blank move to get
+		// there
+		blank := *BlankCount
+		share := n/len(air) - blank
+		for iX, a := range air {
+			z := p.zone(iX, len(air))
+			// the whole frame's worth of points is used up, decimated
+			// into the share
+			all := a.client.take(n, a.rate, outRate)
+			seg := make([]Point, max(share, 1))
+			for iY := range seg {
+				seg[iY] = z.into(all[iY*len(all)/len(seg)])
+			}
+			for iY := 0; iY < blank; iY++ {
+				pts = append(pts, *NewPoint(int(seg[0].X), int(seg[0].Y), BlankColor))
+			}
+			pts = append(pts, seg...)
+		}
+	}
+	if len(pts) > 0 {
+		p.last = pts[len(pts)-1]
+	}
+	return Frame{Points: pts}, nil
+}
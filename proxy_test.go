@@ -0,0 +1,141 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package etherdream
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+func TestZoneInto(t *testing.T) {
+	z := Zone{MinX: -1000, MinY: 0, MaxX: 1000, MaxY: 500}
+	for _, c := range []struct{ in, want Point }{
+		{Point{X: -32768, Y: -32768}, Point{X: -1000, Y: 0}},
+		{Point{X: 32767, Y: 32767}, Point{X: 1000, Y: 500}},
+		{Point{X: 0, Y: 0}, Point{X: 0, Y: 250}},
+	} {
+		if got := z.into(c.in); got != c.want {
+			t.Errorf("into(%v, %v) = %v, %v, want %v, %v", c.in.X, c.in.Y, got.X, got.Y, c.want.X, c.want.Y)
+		}
+	}
+}
+
+// proxyClients connects a DAC to each port of p and starts it playing
+func proxyClients(t *testing.T, p *Proxy, ports ...string) {
+	t.Helper()
+	for _, port := range ports {
+		d, err := NewDACPort("127.0.0.1", port)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { d.Close() })
+		if err := d.WriteFrame(context.Background(), testFrame(1500)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func onAir(p *Proxy) []bool {
+	var ret []bool
+	for _, c := range p.Clients() {
+		ret = append(ret, c.OnAir)
+	}
+	return ret
+}
+
+func TestProxyExclusive(t *testing.T) {
+	p, err := NewProxy("127.0.0.1:0", ProxyExclusive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	proxyClients(t, p, p.Port(), p.Port())
+
+	// looking doesn't hand out the turn
+	if got := onAir(p); got[0] || got[1] {
+		t.Errorf("on air before a frame: %v", got)
+	}
+	if p.owner != nil {
+		t.Errorf("Clients picked an owner")
+	}
+
+	for iX := 0; iX < 2; iX++ {
+		f, err := p.NextFrame(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Points) != FramePoints() {
+			t.Errorf("frame of %v points, want %v", len(f.Points), FramePoints())
+		}
+		if got := onAir(p); !got[0] || got[1] {
+			t.Errorf("frame %v on air %v, want the first client only", iX, got)
+		}
+	}
+}
+
+func TestProxyPriorityByPort(t *testing.T) {
+	p, err := NewProxy("127.0.0.1:0", ProxyPriority)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	la, err := p.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hi := ":" + strconv.Itoa(la.Port)
+	p.Priorities = map[string]int{hi: 10}
+
+	// the newest client would win a tie, the port outranks it
+	proxyClients(t, p, hi[1:], p.Port())
+	if _, err := p.NextFrame(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	cs := p.Clients()
+	if cs[0].Priority != 10 || cs[1].Priority != 0 {
+		t.Errorf("priorities %v, %v, want 10, 0", cs[0].Priority, cs[1].Priority)
+	}
+	if !cs[0].OnAir || cs[1].OnAir {
+		t.Errorf("on air %v, %v, want the client on %v", cs[0].OnAir, cs[1].OnAir, hi)
+	}
+}
+
+func TestProxyZones(t *testing.T) {
+	p, err := NewProxy("127.0.0.1:0", ProxyZones)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.Zones = []Zone{{MinX: -32768, MaxX: -1}, {MinX: 0, MaxX: 32767}}
+	proxyClients(t, p, p.Port(), p.Port())
+
+	f, err := p.NextFrame(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := onAir(p); !got[0] || !got[1] {
+		t.Errorf("on air %v, want both", got)
+	}
+	// the first half is drawn left of centre, the second right of it
+	half := len(f.Points) / 2
+	for iX, pt := range f.Points {
+		if left := pt.X < 0; left != (iX < half) {
+			t.Fatalf("point %v at x %v is in the wrong zone", iX, pt.X)
+		}
+	}
+}
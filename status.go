@@ -23,6 +23,28 @@ import (
 	"fmt"
 )
 
+// Light engine states
+const (
+	LightEngineReady    = 0
+	LightEngineWarmup   = 1
+	LightEngineCooldown = 2
+	LightEngineEStop    = 3
+)
+
+// Playback states
+const (
+	PlaybackIdle     = 0
+	PlaybackPrepared = 1
+	PlaybackPlaying  = 2
+)
+
+// Playback flags
+const (
+	PlaybackFlagShutter   = 1
+	PlaybackFlagUnderflow = 2
+	PlaybackFlagEStop     = 4
+)
+
 // DACStatus is a struct of status informaion sent by the etherdream DAC
 type DACStatus struct {
 	Protocol         uint8
@@ -51,6 +73,34 @@ func NewDACStatus(b []byte) *DACStatus {
 		PointCount:       binary.LittleEndian.Uint32(b[16:20]),
 	}
 }
+
+// Encode the status to the 20 byte wire format
+func (st DACStatus) Encode() []byte {
+	b := make([]byte, 20)
+	b[0] = st.Protocol
+	b[1] = st.LightEngineState
+	b[2] = st.PlaybackState
+	b[3] = st.Source
+	binary.LittleEndian.PutUint16(b[4:6], st.LightEngineFlags)
+	binary.LittleEndian.PutUint16(b[6:8], st.PlaybackFlags)
+	binary.LittleEndian.PutUint16(b[8:10], st.SourceFlags)
+	binary.LittleEndian.PutUint16(b[10:12], st.BufferFullness)
+	binary.LittleEndian.PutUint32(b[12:16], st.PointRate)
+	binary.LittleEndian.PutUint32(b[16:20], st.PointCount)
+	return b
+}
+
+// Underflow is true when the DAC ran out of points while playing
+func (st DACStatus) Underflow() bool {
+	return st.PlaybackFlags&PlaybackFlagUnderflow != 0
+}
+
+// EStop is true when the light engine is in the E-Stop state
+func (st DACStatus) EStop() bool {
+	return st.LightEngineState == LightEngineEStop ||
+		st.PlaybackFlags&PlaybackFlagEStop != 0
+}
+
 func (st DACStatus) String() string {
 	return fmt.Sprintf("Light engine: state %d, flags 0x%x\n", st.LightEngineState, st.LightEngineFlags) +
 		fmt.Sprintf("Playback: state %d, flags 0x%x\n", st.PlaybackState, st.PlaybackFlags) +
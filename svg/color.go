@@ -0,0 +1,110 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package svg
+
+import (
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// named colors, the CSS basic set plus a few common extras
+var namedColors = map[string]color.RGBA{
+	"black":   {0x00, 0x00, 0x00, 0xff},
+	"silver":  {0xc0, 0xc0, 0xc0, 0xff},
+	"gray":    {0x80, 0x80, 0x80, 0xff},
+	"grey":    {0x80, 0x80, 0x80, 0xff},
+	"white":   {0xff, 0xff, 0xff, 0xff},
+	"maroon":  {0x80, 0x00, 0x00, 0xff},
+	"red":     {0xff, 0x00, 0x00, 0xff},
+	"purple":  {0x80, 0x00, 0x80, 0xff},
+	"fuchsia": {0xff, 0x00, 0xff, 0xff},
+	"magenta": {0xff, 0x00, 0xff, 0xff},
+	"green":   {0x00, 0x80, 0x00, 0xff},
+	"lime":    {0x00, 0xff, 0x00, 0xff},
+	"olive":   {0x80, 0x80, 0x00, 0xff},
+	"yellow":  {0xff, 0xff, 0x00, 0xff},
+	"navy":    {0x00, 0x00, 0x80, 0xff},
+	"blue":    {0x00, 0x00, 0xff, 0xff},
+	"teal":    {0x00, 0x80, 0x80, 0xff},
+	"aqua":    {0x00, 0xff, 0xff, 0xff},
+	"cyan":    {0x00, 0xff, 0xff, 0xff},
+	"orange":  {0xff, 0xa5, 0x00, 0xff},
+	"pink":    {0xff, 0xc0, 0xcb, 0xff},
+	"violet":  {0xee, 0x82, 0xee, 0xff},
+	"gold":    {0xff, 0xd7, 0x00, 0xff},
+}
+
+// parseColor reads a paint value. ok is false for "none", and for
+// values that are not colors like gradients.
+func parseColor(s string) (c color.Color, ok bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case s == "" || s == "none" || s == "transparent":
+		return nil, false
+	case strings.HasPrefix(s, "#"):
+		return parseHex(s[1:])
+	case strings.HasPrefix(s, "rgb(") && strings.HasSuffix(s, ")"):
+		return parseRGB(s[4 : len(s)-1])
+	}
+	if c, ok := namedColors[s]; ok {
+		return c, true
+	}
+	return nil, false
+}
+
+func parseHex(h string) (color.Color, bool) {
+	if len(h) == 3 {
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	}
+	if len(h) != 6 {
+		return nil, false
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil {
+		return nil, false
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}, true
+}
+
+func parseRGB(s string) (color.Color, bool) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	var rgb [3]uint8
+	for iX, p := range parts {
+		p = strings.TrimSpace(p)
+		scale := 1.0
+		if strings.HasSuffix(p, "%") {
+			p = p[:len(p)-1]
+			scale = 2.55
+		}
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return nil, false
+		}
+		v *= scale
+		if v < 0 {
+			v = 0
+		} else if v > 255 {
+			v = 255
+		}
+		rgb[iX] = uint8(v + 0.5)
+	}
+	return color.RGBA{rgb[0], rgb[1], rgb[2], 0xff}, true
+}
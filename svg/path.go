@@ -0,0 +1,387 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type vec struct {
+	x, y float64
+}
+
+func (a vec) add(b vec) vec             { return vec{a.x + b.x, a.y + b.y} }
+func (a vec) sub(b vec) vec             { return vec{a.x - b.x, a.y - b.y} }
+func (a vec) mul(s float64) vec         { return vec{a.x * s, a.y * s} }
+func (a vec) lerp(b vec, t float64) vec { return a.add(b.sub(a).mul(t)) }
+
+// segment is a line (two points) or a cubic bezier (four points)
+type segment []vec
+
+// subpath is a run of connected segments
+type subpath struct {
+	segs   []segment
+	closed bool
+}
+
+// pathBuilder collects subpaths in user space, curves are kept as
+// cubics and flattened once the final transform is known
+type pathBuilder struct {
+	subs  []subpath
+	cur   *subpath
+	start vec
+	pos   vec
+}
+
+func (b *pathBuilder) moveTo(p vec) {
+	b.subs = append(b.subs, subpath{})
+	b.cur = &b.subs[len(b.subs)-1]
+	b.start, b.pos = p, p
+}
+
+func (b *pathBuilder) ensure() {
+	if b.cur == nil {
+		b.moveTo(b.pos)
+	}
+}
+
+func (b *pathBuilder) lineTo(p vec) {
+	b.ensure()
+	b.cur.segs = append(b.cur.segs, segment{b.pos, p})
+	b.pos = p
+}
+
+func (b *pathBuilder) cubicTo(c1, c2, p vec) {
+	b.ensure()
+	b.cur.segs = append(b.cur.segs, segment{b.pos, c1, c2, p})
+	b.pos = p
+}
+
+func (b *pathBuilder) quadTo(c, p vec) {
+	// raise the quadratic to a cubic
+	b.cubicTo(b.pos.lerp(c, 2.0/3.0), p.lerp(c, 2.0/3.0), p)
+}
+
+func (b *pathBuilder) close() {
+	if b.cur == nil {
+		return
+	}
+	if b.pos != b.start {
+		b.lineTo(b.start)
+	}
+	b.cur.closed = true
+	b.cur = nil
+	b.pos = b.start
+}
+
+// arcTo adds an elliptical arc in SVG endpoint form as cubics, see
+// the SVG implementation notes F.6.5
+func (b *pathBuilder) arcTo(rx, ry, rot float64, large, sweep bool, p vec) {
+	p0 := b.pos
+	if p0 == p {
+		return
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		b.lineTo(p)
+		return
+	}
+
+	phi := rot * math.Pi / 180
+	cos, sin := math.Cos(phi), math.Sin(phi)
+	dx, dy := (p0.x-p.x)/2, (p0.y-p.y)/2
+	x1 := cos*dx + sin*dy
+	y1 := -sin*dx + cos*dy
+
+	// scale up radii that are too small to reach
+	if l := x1*x1/(rx*rx) + y1*y1/(ry*ry); l > 1 {
+		rx *= math.Sqrt(l)
+		ry *= math.Sqrt(l)
+	}
+
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	co := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		co = -co
+	}
+	cx1 := co * rx * y1 / ry
+	cy1 := -co * ry * x1 / rx
+	cx := cos*cx1 - sin*cy1 + (p0.x+p.x)/2
+	cy := sin*cx1 + cos*cy1 + (p0.y+p.y)/2
+
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	t1 := angle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	dt := angle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && dt > 0 {
+		dt -= 2 * math.Pi
+	} else if sweep && dt < 0 {
+		dt += 2 * math.Pi
+	}
+
+	b.ellipse(vec{cx, cy}, rx, ry, phi, t1, dt)
+	b.pos = p
+}
+
+// ellipse adds cubics along an ellipse from angle t1 through dt,
+// in pieces of no more than 90 degrees
+func (b *pathBuilder) ellipse(c vec, rx, ry, phi, t1, dt float64) {
+	cos, sin := math.Cos(phi), math.Sin(phi)
+	pt := func(t float64) vec {
+		x, y := rx*math.Cos(t), ry*math.Sin(t)
+		return vec{c.x + cos*x - sin*y, c.y + sin*x + cos*y}
+	}
+	deriv := func(t float64) vec {
+		x, y := -rx*math.Sin(t), ry*math.Cos(t)
+		return vec{cos*x - sin*y, sin*x + cos*y}
+	}
+
+	n := int(math.Ceil(math.Abs(dt) / (math.Pi / 2)))
+	step := dt / float64(n)
+	k := 4.0 / 3.0 * math.Tan(step/4)
+	for iX := 0; iX < n; iX++ {
+		a := t1 + step*float64(iX)
+		z := a + step
+		b.cubicTo(pt(a).add(deriv(a).mul(k)), pt(z).sub(deriv(z).mul(k)), pt(z))
+	}
+}
+
+// parsePath reads path data from a d attribute
+func parsePath(d string, b *pathBuilder) error {
+	t := &tokenizer{s: d}
+	var cmd byte
+	var lastCtrl vec
+	var lastCmd byte
+	for {
+		t.skip()
+		if t.done() {
+			return nil
+		}
+		if c := t.s[t.i]; isCommand(c) {
+			cmd = c
+			t.i++
+		} else if cmd == 0 {
+			return fmt.Errorf("svg: path data must start with a command: %q", d)
+		}
+
+		rel := cmd >= 'a'
+		base := vec{}
+		if rel {
+			base = b.pos
+		}
+		pt := func() (vec, error) {
+			x, err := t.number()
+			if err != nil {
+				return vec{}, err
+			}
+			y, err := t.number()
+			return base.add(vec{x, y}), err
+		}
+		// commands without arguments don't repeat
+		upper := cmd &^ 0x20
+		if upper == 'Z' {
+			b.close()
+			lastCmd, cmd = 'Z', 0
+			continue
+		}
+
+		var err error
+		switch upper {
+		case 'M':
+			var p vec
+			if p, err = pt(); err == nil {
+				b.moveTo(p)
+				// pairs after a move are implicit line tos
+				if rel {
+					cmd = 'l'
+				} else {
+					cmd = 'L'
+				}
+			}
+		case 'L':
+			var p vec
+			if p, err = pt(); err == nil {
+				b.lineTo(p)
+			}
+		case 'H':
+			var x float64
+			if x, err = t.number(); err == nil {
+				b.lineTo(vec{base.x + x, b.pos.y})
+			}
+		case 'V':
+			var y float64
+			if y, err = t.number(); err == nil {
+				b.lineTo(vec{b.pos.x, base.y + y})
+			}
+		case 'C':
+			var c1, c2, p vec
+			if c1, err = pt(); err == nil {
+				if c2, err = pt(); err == nil {
+					if p, err = pt(); err == nil {
+						b.cubicTo(c1, c2, p)
+						lastCtrl = c2
+					}
+				}
+			}
+		case 'S':
+			var c2, p vec
+			c1 := b.pos
+			if lastCmd == 'C' || lastCmd == 'S' {
+				c1 = b.pos.add(b.pos.sub(lastCtrl))
+			}
+			if c2, err = pt(); err == nil {
+				if p, err = pt(); err == nil {
+					b.cubicTo(c1, c2, p)
+					lastCtrl = c2
+				}
+			}
+		case 'Q':
+			var c, p vec
+			if c, err = pt(); err == nil {
+				if p, err = pt(); err == nil {
+					b.quadTo(c, p)
+					lastCtrl = c
+				}
+			}
+		case 'T':
+			var p vec
+			c := b.pos
+			if lastCmd == 'Q' || lastCmd == 'T' {
+				c = b.pos.add(b.pos.sub(lastCtrl))
+			}
+			if p, err = pt(); err == nil {
+				b.quadTo(c, p)
+				lastCtrl = c
+			}
+		case 'A':
+			var rx, ry, rot float64
+			var large, sweep bool
+			var p vec
+			if rx, err = t.number(); err != nil {
+				break
+			}
+			if ry, err = t.number(); err != nil {
+				break
+			}
+			if rot, err = t.number(); err != nil {
+				break
+			}
+			if large, err = t.flag(); err != nil {
+				break
+			}
+			if sweep, err = t.flag(); err != nil {
+				break
+			}
+			if p, err = pt(); err == nil {
+				b.arcTo(rx, ry, rot, large, sweep, p)
+			}
+		default:
+			err = fmt.Errorf("svg: unknown path command %q", cmd)
+		}
+		if err != nil {
+			return err
+		}
+		lastCmd = upper
+	}
+}
+
+func isCommand(c byte) bool {
+	return strings.IndexByte("MmLlHhVvCcSsQqTtAaZz", c) >= 0
+}
+
+// tokenizer splits path data and number lists, numbers can run
+// together as in "1.5.5-2"
+type tokenizer struct {
+	s string
+	i int
+}
+
+func (t *tokenizer) skip() {
+	for t.i < len(t.s) && strings.IndexByte(" \t\r\n,", t.s[t.i]) >= 0 {
+		t.i++
+	}
+}
+
+func (t *tokenizer) done() bool {
+	return t.i >= len(t.s)
+}
+
+func (t *tokenizer) number() (float64, error) {
+	t.skip()
+	start := t.i
+	if t.i < len(t.s) && (t.s[t.i] == '-' || t.s[t.i] == '+') {
+		t.i++
+	}
+	dot, exp := false, false
+	for t.i < len(t.s) {
+		c := t.s[t.i]
+		switch {
+		case c >= '0' && c <= '9':
+		case c == '.' && !dot && !exp:
+			dot = true
+		case (c == 'e' || c == 'E') && !exp && t.i > start:
+			exp = true
+			if t.i+1 < len(t.s) && (t.s[t.i+1] == '-' || t.s[t.i+1] == '+') {
+				t.i++
+			}
+		default:
+			return t.parse(start)
+		}
+		t.i++
+	}
+	return t.parse(start)
+}
+
+func (t *tokenizer) parse(start int) (float64, error) {
+	v, err := strconv.ParseFloat(t.s[start:t.i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("svg: bad number at %d in %q", start, t.s)
+	}
+	return v, nil
+}
+
+// flag reads an arc flag, which may be packed against the next value
+func (t *tokenizer) flag() (bool, error) {
+	t.skip()
+	if t.done() || (t.s[t.i] != '0' && t.s[t.i] != '1') {
+		return false, fmt.Errorf("svg: bad arc flag at %d in %q", t.i, t.s)
+	}
+	t.i++
+	return t.s[t.i-1] == '1', nil
+}
+
+// parseNumbers reads a list of numbers as used by points and transforms
+func parseNumbers(s string) ([]float64, error) {
+	t := &tokenizer{s: s}
+	var ret []float64
+	for {
+		t.skip()
+		if t.done() {
+			return ret, nil
+		}
+		v, err := t.number()
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, v)
+	}
+}
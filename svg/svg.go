@@ -0,0 +1,411 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package svg loads SVG drawings as colored ln paths for the laser.
+// The path, line, polyline, polygon, rect, circle and ellipse elements
+// are supported, with transforms and stroke colors. Curves are
+// flattened to line segments and the drawing is fitted into the DAC
+// coordinate range.
+package svg
+
+import (
+	"encoding/xml"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/ln/ln"
+)
+
+// Options for loading a drawing
+type Options struct {
+	// Tolerance is the furthest a flattened curve may stray from the
+	// real curve, in DAC units. Defaults to 40.
+	Tolerance float64
+	// Color is used for shapes with no stroke or fill color, and for
+	// black, which a laser can't draw. Defaults to white.
+	Color color.Color
+	// Extent is half the width of the square the viewBox is fitted
+	// into, centered on 0. Defaults to 32767, the full DAC range.
+	Extent float64
+}
+
+// Load reads the named SVG file
+func Load(name string, opts Options) (etherdream.ColorPaths, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f, opts)
+}
+
+// state is inherited from parent elements
+type state struct {
+	ctm    matrix
+	stroke string
+	fill   string
+	hidden bool
+}
+
+// shape is one element's geometry, already transformed to the
+// coordinates of the root viewBox
+type shape struct {
+	subs  []subpath
+	color color.Color
+}
+
+// Parse reads an SVG document from r
+func Parse(r io.Reader, opts Options) (etherdream.ColorPaths, error) {
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = 40
+	}
+	if opts.Color == nil {
+		opts.Color = color.White
+	}
+	if opts.Extent <= 0 {
+		opts.Extent = 32767
+	}
+
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	stack := []state{{ctm: identity, stroke: "none", fill: "black"}}
+	var shapes []shape
+	var viewBox []float64
+	root := true
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			attrs := attrMap(t.Attr)
+			st, err := inherit(stack[len(stack)-1], attrs)
+			if err != nil {
+				return nil, err
+			}
+
+			name := t.Name.Local
+			if root && name == "svg" {
+				viewBox = rootViewBox(attrs)
+			}
+			root = false
+
+			switch name {
+			case "defs", "clipPath", "mask", "symbol", "marker", "pattern",
+				"linearGradient", "radialGradient", "title", "desc",
+				"metadata", "style", "script", "text":
+				// nothing drawable, or nothing we can draw
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			stack = append(stack, st)
+			if st.hidden {
+				continue
+			}
+			b := &pathBuilder{}
+			if err := build(name, attrs, b); err != nil {
+				return nil, err
+			}
+			if len(b.subs) == 0 {
+				continue
+			}
+			c, ok := paint(st, opts)
+			if !ok {
+				continue
+			}
+			for iX := range b.subs {
+				for _, seg := range b.subs[iX].segs {
+					for jX := range seg {
+						seg[jX] = st.ctm.apply(seg[jX])
+					}
+				}
+			}
+			shapes = append(shapes, shape{b.subs, c})
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+
+	fit := fitMatrix(viewBox, shapes, opts.Extent)
+	var paths etherdream.ColorPaths
+	for _, sh := range shapes {
+		for _, sub := range sh.subs {
+			p := flattenSubpath(sub, fit, opts.Tolerance)
+			if len(p) > 1 {
+				paths = append(paths, etherdream.ColorPath{Path: p, Color: sh.color})
+			}
+		}
+	}
+	return paths, nil
+}
+
+func attrMap(attrs []xml.Attr) map[string]string {
+	m := map[string]string{}
+	for _, a := range attrs {
+		m[a.Name.Local] = a.Value
+	}
+	// style properties override presentation attributes
+	for _, decl := range strings.Split(m["style"], ";") {
+		kv := strings.SplitN(decl, ":", 2)
+		if len(kv) == 2 {
+			m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return m
+}
+
+func inherit(parent state, attrs map[string]string) (state, error) {
+	st := parent
+	if v, ok := attrs["transform"]; ok {
+		m, err := parseTransform(v)
+		if err != nil {
+			return st, err
+		}
+		st.ctm = m.mul(parent.ctm)
+	}
+	if v, ok := attrs["stroke"]; ok && v != "inherit" {
+		st.stroke = v
+	}
+	if v, ok := attrs["fill"]; ok && v != "inherit" {
+		st.fill = v
+	}
+	if attrs["display"] == "none" || attrs["visibility"] == "hidden" {
+		st.hidden = true
+	}
+	return st, nil
+}
+
+// paint picks the laser color, stroke first then fill. ok is false
+// when the shape is not painted at all.
+func paint(st state, opts Options) (c color.Color, ok bool) {
+	c, ok = parseColor(st.stroke)
+	if !ok {
+		c, ok = parseColor(st.fill)
+	}
+	if !ok {
+		if st.stroke == "none" && st.fill == "none" {
+			return nil, false
+		}
+		return opts.Color, true
+	}
+	if r, g, b, _ := c.RGBA(); r == 0 && g == 0 && b == 0 {
+		return opts.Color, true
+	}
+	return c, true
+}
+
+// build adds the geometry of one element in its own user space
+func build(name string, a map[string]string, b *pathBuilder) error {
+	num := func(k string) float64 {
+		return length(a[k])
+	}
+	switch name {
+	case "path":
+		return parsePath(a["d"], b)
+	case "line":
+		b.moveTo(vec{num("x1"), num("y1")})
+		b.lineTo(vec{num("x2"), num("y2")})
+	case "polyline", "polygon":
+		pts, err := parseNumbers(a["points"])
+		if err != nil {
+			return err
+		}
+		for iX := 0; iX+1 < len(pts); iX += 2 {
+			p := vec{pts[iX], pts[iX+1]}
+			if iX == 0 {
+				b.moveTo(p)
+			} else {
+				b.lineTo(p)
+			}
+		}
+		if name == "polygon" {
+			b.close()
+		}
+	case "rect":
+		x, y, w, h := num("x"), num("y"), num("width"), num("height")
+		if w <= 0 || h <= 0 {
+			return nil
+		}
+		rx, rxok := a["rx"]
+		ry, ryok := a["ry"]
+		if !rxok {
+			rx = ry
+		}
+		if !ryok {
+			ry = rx
+		}
+		r := vec{math.Min(length(rx), w/2), math.Min(length(ry), h/2)}
+		if r.x <= 0 || r.y <= 0 {
+			b.moveTo(vec{x, y})
+			b.lineTo(vec{x + w, y})
+			b.lineTo(vec{x + w, y + h})
+			b.lineTo(vec{x, y + h})
+			b.close()
+			return nil
+		}
+		b.moveTo(vec{x + r.x, y})
+		b.lineTo(vec{x + w - r.x, y})
+		b.arcTo(r.x, r.y, 0, false, true, vec{x + w, y + r.y})
+		b.lineTo(vec{x + w, y + h - r.y})
+		b.arcTo(r.x, r.y, 0, false, true, vec{x + w - r.x, y + h})
+		b.lineTo(vec{x + r.x, y + h})
+		b.arcTo(r.x, r.y, 0, false, true, vec{x, y + h - r.y})
+		b.lineTo(vec{x, y + r.y})
+		b.arcTo(r.x, r.y, 0, false, true, vec{x + r.x, y})
+		b.close()
+	case "circle", "ellipse":
+		c := vec{num("cx"), num("cy")}
+		rx, ry := num("r"), num("r")
+		if name == "ellipse" {
+			rx, ry = num("rx"), num("ry")
+		}
+		if rx <= 0 || ry <= 0 {
+			return nil
+		}
+		b.moveTo(vec{c.x + rx, c.y})
+		b.ellipse(c, rx, ry, 0, 0, 2*math.Pi)
+		b.close()
+	}
+	return nil
+}
+
+// length reads a number and drops any unit
+func length(s string) float64 {
+	s = strings.TrimSpace(s)
+	end := len(s)
+	for end > 0 && strings.IndexByte("0123456789.", s[end-1]) < 0 {
+		end--
+	}
+	v, _ := strconv.ParseFloat(s[:end], 64)
+	return v
+}
+
+// rootViewBox is the viewBox of the outer svg element, or its width
+// and height
+func rootViewBox(a map[string]string) []float64 {
+	if vb, err := parseNumbers(a["viewBox"]); err == nil && len(vb) == 4 && vb[2] > 0 && vb[3] > 0 {
+		return vb
+	}
+	w, h := length(a["width"]), length(a["height"])
+	if w > 0 && h > 0 {
+		return []float64{0, 0, w, h}
+	}
+	return nil
+}
+
+// fitMatrix maps the viewBox, or the bounds of the drawing when there
+// isn't one, to a square of +/- extent with y pointing up
+func fitMatrix(vb []float64, shapes []shape, extent float64) matrix {
+	if vb == nil {
+		min := vec{math.Inf(1), math.Inf(1)}
+		max := vec{math.Inf(-1), math.Inf(-1)}
+		for _, sh := range shapes {
+			for _, sub := range sh.subs {
+				for _, seg := range sub.segs {
+					for _, p := range seg {
+						min = vec{math.Min(min.x, p.x), math.Min(min.y, p.y)}
+						max = vec{math.Max(max.x, p.x), math.Max(max.y, p.y)}
+					}
+				}
+			}
+		}
+		if math.IsInf(min.x, 0) {
+			return identity
+		}
+		vb = []float64{min.x, min.y, max.x - min.x, max.y - min.y}
+	}
+
+	size := math.Max(vb[2], vb[3])
+	if size == 0 {
+		size = 1
+	}
+	s := 2 * extent / size
+	cx, cy := vb[0]+vb[2]/2, vb[1]+vb[3]/2
+	return matrix{s, 0, 0, -s, -cx * s, cy * s}
+}
+
+// flattenSubpath transforms a subpath to DAC coordinates and turns its
+// curves into line segments
+func flattenSubpath(sub subpath, fit matrix, tol float64) ln.Path {
+	var pts []vec
+	for _, seg := range sub.segs {
+		s := make(segment, len(seg))
+		for iX, p := range seg {
+			s[iX] = fit.apply(p)
+		}
+		if len(pts) == 0 {
+			pts = append(pts, s[0])
+		}
+		if len(s) == 2 {
+			pts = append(pts, s[1])
+		} else {
+			pts = flattenCubic(s, tol, pts, 0)
+		}
+	}
+
+	var p ln.Path
+	for _, v := range pts {
+		lv := ln.Vector{X: math.Round(v.x), Y: math.Round(v.y)}
+		if len(p) > 0 && p[len(p)-1] == lv {
+			continue
+		}
+		p = append(p, lv)
+	}
+	return p
+}
+
+// flattenCubic subdivides until the control points are within tol of
+// the chord
+func flattenCubic(s segment, tol float64, out []vec, depth int) []vec {
+	if depth >= 16 || cubicFlat(s, tol) {
+		return append(out, s[3])
+	}
+	p01, p12, p23 := s[0].lerp(s[1], 0.5), s[1].lerp(s[2], 0.5), s[2].lerp(s[3], 0.5)
+	p012, p123 := p01.lerp(p12, 0.5), p12.lerp(p23, 0.5)
+	mid := p012.lerp(p123, 0.5)
+	out = flattenCubic(segment{s[0], p01, p012, mid}, tol, out, depth+1)
+	return flattenCubic(segment{mid, p123, p23, s[3]}, tol, out, depth+1)
+}
+
+func cubicFlat(s segment, tol float64) bool {
+	return pointLineDistance(s[1], s[0], s[3]) <= tol &&
+		pointLineDistance(s[2], s[0], s[3]) <= tol
+}
+
+func pointLineDistance(p, a, b vec) float64 {
+	d := b.sub(a)
+	l := math.Hypot(d.x, d.y)
+	if l == 0 {
+		return math.Hypot(p.x-a.x, p.y-a.y)
+	}
+	return math.Abs(d.x*(a.y-p.y)-d.y*(a.x-p.x)) / l
+}
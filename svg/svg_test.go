@@ -0,0 +1,250 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package svg
+
+import (
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/ln/ln"
+)
+
+// parse loads body in a 200 square viewBox fitted to +/- 100, so a
+// point x, y lands on x-100, 100-y
+func parse(t *testing.T, body string, opts Options) etherdream.ColorPaths {
+	t.Helper()
+	opts.Extent = 100
+	paths, err := Parse(strings.NewReader(`<svg viewBox="0 0 200 200">`+body+`</svg>`), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func path(xy ...float64) ln.Path {
+	var p ln.Path
+	for iX := 0; iX+1 < len(xy); iX += 2 {
+		p = append(p, ln.Vector{X: xy[iX], Y: xy[iX+1]})
+	}
+	return p
+}
+
+func samePaths(got etherdream.ColorPaths, want []ln.Path) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for iX := range got {
+		if len(got[iX].Path) != len(want[iX]) {
+			return false
+		}
+		for iY, v := range got[iX].Path {
+			if v != want[iX][iY] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestPathCommands(t *testing.T) {
+	square := path(-90, 90, -50, 90, -50, 50, -90, 90)
+	for _, c := range []struct {
+		d    string
+		want []ln.Path
+	}{
+		{"M 10 10 L 50 10 L 50 50 Z", []ln.Path{square}},
+		{"m10,10 l40,0 l0,40 z", []ln.Path{square}},
+		{"M10 10 50 10 50 50z", []ln.Path{square}},
+		{"m10 10 40 0 0 40Z", []ln.Path{square}},
+		{"M10 10H50V50", []ln.Path{path(-90, 90, -50, 90, -50, 50)}},
+		{"M10 10h40v40h-40", []ln.Path{path(-90, 90, -50, 90, -50, 50, -90, 50)}},
+		{"M10-10L50-10", []ln.Path{path(-90, 110, -50, 110)}},
+		{"M1e1 1e1L5e1 1e1", []ln.Path{path(-90, 90, -50, 90)}},
+		// relative after close is from the start of the subpath
+		{"M10 10l10 0zm0 20l10 0", []ln.Path{path(-90, 90, -80, 90, -90, 90), path(-90, 70, -80, 70)}},
+		{"M10 10L20 10M30 30l10 0", []ln.Path{path(-90, 90, -80, 90), path(-70, 70, -60, 70)}},
+		// a straight curve needs no flattening
+		{"M10 10C20 10 30 10 40 10", []ln.Path{path(-90, 90, -60, 90)}},
+		{"M10 10Q20 10 30 10T50 10", []ln.Path{path(-90, 90, -70, 90, -50, 90)}},
+	} {
+		if got := parse(t, `<path d="`+c.d+`"/>`, Options{}); !samePaths(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.d, got, c.want)
+		}
+	}
+}
+
+func TestPathErrors(t *testing.T) {
+	for _, d := range []string{"10 10 L 20 20", "M 10 10 X 20 20", "M 10 10 L 20"} {
+		_, err := Parse(strings.NewReader(`<svg><path d="`+d+`"/></svg>`), Options{})
+		if err == nil {
+			t.Errorf("%q parsed, want an error", d)
+		}
+	}
+}
+
+// onCircle checks every point of p is r from c, give or take rounding
+// and the tolerance
+func onCircle(t *testing.T, name string, p ln.Path, c ln.Vector, r, tol float64) {
+	t.Helper()
+	for _, v := range p {
+		if d := v.Distance(c); math.Abs(d-r) > tol+1 {
+			t.Errorf("%v: point %v is %.1f from the centre, want %v", name, v, d, r)
+			return
+		}
+	}
+}
+
+func TestArcs(t *testing.T) {
+	opts := Options{Tolerance: 0.5}
+	for _, c := range []struct {
+		d    string
+		left bool
+	}{
+		// a quarter from the top to the right of a circle at 100, 100
+		{"M 100 50 A 50 50 0 0 1 150 100", false},
+		{"M 100 50 a 50 50 0 0 1 50 50", false},
+		// the other three quarters
+		{"M 100 50 A 50 50 0 1 0 150 100", true},
+		// radii too small to reach are scaled up, to a half circle
+		{"M 50 100 A 1 1 0 0 1 150 100", true},
+	} {
+		paths := parse(t, `<path d="`+c.d+`"/>`, opts)
+		if len(paths) != 1 {
+			t.Fatalf("%q: %v paths, want 1", c.d, len(paths))
+		}
+		p := paths[0].Path
+		if len(p) < 4 {
+			t.Errorf("%q: %v points, want the arc flattened", c.d, len(p))
+		}
+		onCircle(t, c.d, p, ln.Vector{}, 50, opts.Tolerance)
+		left := false
+		for _, v := range p {
+			left = left || v.X < -25
+		}
+		if left != c.left {
+			t.Errorf("%q: reaches the left side %v, want %v", c.d, left, c.left)
+		}
+	}
+
+	paths := parse(t, `<circle cx="100" cy="100" r="50"/><ellipse cx="100" cy="100" rx="50" ry="50"/>`, opts)
+	if len(paths) != 2 {
+		t.Fatalf("%v paths, want 2", len(paths))
+	}
+	for _, cp := range paths {
+		onCircle(t, "circle", cp.Path, ln.Vector{}, 50, opts.Tolerance)
+		if cp.Path[0] != cp.Path[len(cp.Path)-1] {
+			t.Errorf("circle is not closed")
+		}
+	}
+}
+
+func TestTransforms(t *testing.T) {
+	line := `<line x1="0" y1="0" x2="10" y2="0"/>`
+	for _, c := range []struct {
+		svg  string
+		want ln.Path
+	}{
+		{`<g transform="translate(100,100)">` + line + `</g>`, path(0, 0, 10, 0)},
+		{`<g transform="translate(100 100)"><g transform="scale(2)">` + line + `</g></g>`, path(0, 0, 20, 0)},
+		// a list applies right to left, like nesting
+		{`<g transform="translate(100,100) scale(2)">` + line + `</g>`, path(0, 0, 20, 0)},
+		{`<g transform="scale(2)"><g transform="translate(50,50)">` + line + `</g></g>`, path(0, 0, 20, 0)},
+		// y points down in SVG and up on the laser
+		{`<g transform="translate(100,100)"><g transform="rotate(90)">` + line + `</g></g>`, path(0, 0, 0, -10)},
+		{`<g transform="rotate(90 100 100)"><line x1="100" y1="100" x2="110" y2="100"/></g>`, path(0, 0, 0, -10)},
+		{`<g transform="matrix(1 0 0 1 100 100)">` + line + `</g>`, path(0, 0, 10, 0)},
+		{`<line transform="translate(100,100)" x1="0" y1="0" x2="10" y2="0"/>`, path(0, 0, 10, 0)},
+	} {
+		if got := parse(t, c.svg, Options{}); !samePaths(got, []ln.Path{c.want}) {
+			t.Errorf("%v: got %v, want %v", c.svg, got, c.want)
+		}
+	}
+
+	if _, err := Parse(strings.NewReader(`<svg><g transform="spin(3)">`+line+`</g></svg>`), Options{}); err == nil {
+		t.Errorf("unknown transform parsed")
+	}
+}
+
+func TestShapesAndUnits(t *testing.T) {
+	for _, c := range []struct {
+		svg  string
+		want ln.Path
+	}{
+		{`<rect x="10px" y="10px" width="40px" height="40px"/>`, path(-90, 90, -50, 90, -50, 50, -90, 50, -90, 90)},
+		{`<polyline points="10,10 50,10 50,50"/>`, path(-90, 90, -50, 90, -50, 50)},
+		{`<polygon points="10 10 50 10 50 50"/>`, path(-90, 90, -50, 90, -50, 50, -90, 90)},
+		{`<line x1="10mm" y1="10" x2="50.5" y2="10"/>`, path(-90, 90, -50, 90)},
+	} {
+		if got := parse(t, c.svg, Options{}); !samePaths(got, []ln.Path{c.want}) {
+			t.Errorf("%v: got %v, want %v", c.svg, got, c.want)
+		}
+	}
+
+	// without a viewBox the width and height are used
+	paths, err := Parse(strings.NewReader(`<svg width="200px" height="200px"><line x1="0" y1="100" x2="200" y2="100"/></svg>`), Options{Extent: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := path(-100, 0, 100, 0); !samePaths(paths, []ln.Path{want}) {
+		t.Errorf("width and height: got %v, want %v", paths, want)
+	}
+}
+
+func TestColors(t *testing.T) {
+	def := color.RGBA{1, 2, 3, 0xff}
+	for _, c := range []struct {
+		attrs string
+		want  color.Color
+	}{
+		{`stroke="#f00"`, color.RGBA{0xff, 0, 0, 0xff}},
+		{`stroke="#00FF00"`, color.RGBA{0, 0xff, 0, 0xff}},
+		{`stroke="rgb(0, 0, 255)"`, color.RGBA{0, 0, 0xff, 0xff}},
+		{`stroke="rgb(100%, 0%, 20%)"`, color.RGBA{0xff, 0, 0x33, 0xff}},
+		{`style="stroke: teal"`, color.RGBA{0, 0x80, 0x80, 0xff}},
+		// style beats the attribute
+		{`stroke="red" style="stroke:lime"`, color.RGBA{0, 0xff, 0, 0xff}},
+		{`stroke="none" fill="yellow"`, color.RGBA{0xff, 0xff, 0, 0xff}},
+		// black can't be drawn, and no paint at all uses the default
+		{`stroke="black"`, def},
+		{``, def},
+		{`stroke="url(#grad)"`, def},
+	} {
+		paths := parse(t, `<line x1="0" y1="0" x2="10" y2="0" `+c.attrs+`/>`, Options{Color: def})
+		if len(paths) != 1 {
+			t.Errorf("%v: %v paths, want 1", c.attrs, len(paths))
+			continue
+		}
+		if paths[0].Color != c.want {
+			t.Errorf("%v: color %v, want %v", c.attrs, paths[0].Color, c.want)
+		}
+	}
+
+	// unpainted and hidden shapes are left out, as are inherited ones
+	for _, body := range []string{
+		`<line x1="0" y1="0" x2="10" y2="0" stroke="none" fill="none"/>`,
+		`<line x1="0" y1="0" x2="10" y2="0" display="none"/>`,
+		`<g style="visibility: hidden"><line x1="0" y1="0" x2="10" y2="0"/></g>`,
+		`<defs><line x1="0" y1="0" x2="10" y2="0"/></defs>`,
+	} {
+		if paths := parse(t, body, Options{}); len(paths) != 0 {
+			t.Errorf("%v: %v paths, want none", body, len(paths))
+		}
+	}
+}
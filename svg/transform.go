@@ -0,0 +1,106 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package svg
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// matrix is a 2D affine transform in SVG order: a b c d e f maps
+// (x, y) to (a*x + c*y + e, b*x + d*y + f)
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// mul returns m then n, n is applied to the result of m
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		n[0]*m[0] + n[2]*m[1],
+		n[1]*m[0] + n[3]*m[1],
+		n[0]*m[2] + n[2]*m[3],
+		n[1]*m[2] + n[3]*m[3],
+		n[0]*m[4] + n[2]*m[5] + n[4],
+		n[1]*m[4] + n[3]*m[5] + n[5],
+	}
+}
+
+func (m matrix) apply(p vec) vec {
+	return vec{m[0]*p.x + m[2]*p.y + m[4], m[1]*p.x + m[3]*p.y + m[5]}
+}
+
+// parseTransform reads a transform attribute, a list of matrix,
+// translate, scale, rotate, skewX and skewY functions applied right to
+// left.
+func parseTransform(s string) (matrix, error) {
+	m := identity
+	s = strings.TrimSpace(s)
+	for s != "" {
+		open := strings.IndexByte(s, '(')
+		close := strings.IndexByte(s, ')')
+		if open < 0 || close < open {
+			return m, fmt.Errorf("svg: bad transform %q", s)
+		}
+		name := strings.Trim(s[:open], " \t\r\n,")
+		args, err := parseNumbers(s[open+1 : close])
+		if err != nil {
+			return m, err
+		}
+		t, err := transformFunc(name, args)
+		if err != nil {
+			return m, err
+		}
+		// each function applies to the coordinates before the ones
+		// to its left
+		m = t.mul(m)
+		s = strings.TrimLeft(s[close+1:], " \t\r\n,")
+	}
+	return m, nil
+}
+
+func transformFunc(name string, a []float64) (matrix, error) {
+	arg := func(i int, def float64) float64 {
+		if i < len(a) {
+			return a[i]
+		}
+		return def
+	}
+	switch name {
+	case "matrix":
+		if len(a) != 6 {
+			return identity, fmt.Errorf("svg: matrix needs 6 values, got %d", len(a))
+		}
+		return matrix{a[0], a[1], a[2], a[3], a[4], a[5]}, nil
+	case "translate":
+		return matrix{1, 0, 0, 1, arg(0, 0), arg(1, 0)}, nil
+	case "scale":
+		sx := arg(0, 1)
+		return matrix{sx, 0, 0, arg(1, sx), 0, 0}, nil
+	case "rotate":
+		r := arg(0, 0) * math.Pi / 180
+		cx, cy := arg(1, 0), arg(2, 0)
+		cos, sin := math.Cos(r), math.Sin(r)
+		rot := matrix{cos, sin, -sin, cos, 0, 0}
+		return matrix{1, 0, 0, 1, -cx, -cy}.mul(rot).mul(matrix{1, 0, 0, 1, cx, cy}), nil
+	case "skewX":
+		return matrix{1, 0, math.Tan(arg(0, 0) * math.Pi / 180), 1, 0, 0}, nil
+	case "skewY":
+		return matrix{1, math.Tan(arg(0, 0) * math.Pi / 180), 0, 1, 0, 0}, nil
+	}
+	return identity, fmt.Errorf("svg: unknown transform %q", name)
+}
@@ -0,0 +1,141 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package text
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tgreiser/ln/ln"
+)
+
+// Hershey coordinates are letters offset from R, y points down and the
+// baseline of capitals is at 9
+const (
+	hersheyOrigin   = 'R'
+	hersheyBaseline = 9
+)
+
+// CapHeight is the height of a capital in font units
+const CapHeight = 21.0
+
+// Glyph is one character. Strokes are in font units with the baseline
+// at y 0, y up and the left edge at x 0.
+type Glyph struct {
+	Advance float64
+	Strokes []ln.Path
+}
+
+// Font maps runes to glyphs. Runes with no glyph are drawn as Missing.
+type Font struct {
+	Glyphs  map[rune]*Glyph
+	Missing rune
+}
+
+// Simplex is the built in single stroke Hershey roman font
+var Simplex = mustFont(simplexData)
+
+func mustFont(data []string) *Font {
+	f := &Font{Glyphs: map[rune]*Glyph{}, Missing: '?'}
+	for iX, s := range data {
+		g, err := decodeGlyph(s)
+		if err != nil {
+			panic(err)
+		}
+		f.Glyphs[rune(' '+iX)] = g
+	}
+	return f
+}
+
+// Glyph returns the glyph for r
+func (f *Font) Glyph(r rune) *Glyph {
+	if g, ok := f.Glyphs[r]; ok {
+		return g
+	}
+	return f.Glyphs[f.Missing]
+}
+
+// ParseJHF reads a Hershey font in JHF format. Glyphs are assigned to
+// runes in order from the space, as in the usual ASCII ordered files.
+func ParseJHF(r io.Reader) (*Font, error) {
+	f := &Font{Glyphs: map[rune]*Glyph{}, Missing: '?'}
+	sc := bufio.NewScanner(r)
+	next := rune(' ')
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(line) < 10 {
+			return nil, fmt.Errorf("text: short JHF record %q", line)
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(line[5:8]))
+		if err != nil {
+			return nil, fmt.Errorf("text: bad JHF vertex count in %q", line)
+		}
+		// long glyphs carry on over the following lines
+		data := line[8:]
+		for len(data) < count*2 && sc.Scan() {
+			data += strings.TrimRight(sc.Text(), "\r")
+		}
+		if len(data) < count*2 {
+			return nil, fmt.Errorf("text: JHF glyph %q is cut short", strings.TrimSpace(line[:5]))
+		}
+		g, err := decodeGlyph(data[:count*2])
+		if err != nil {
+			return nil, err
+		}
+		f.Glyphs[next] = g
+		next++
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// decodeGlyph reads the left and right edges, then vertex pairs with
+// " R" lifting the pen
+func decodeGlyph(s string) (*Glyph, error) {
+	if len(s) < 2 || len(s)%2 != 0 {
+		return nil, fmt.Errorf("text: bad glyph data %q", s)
+	}
+	left := float64(int(s[0]) - hersheyOrigin)
+	right := float64(int(s[1]) - hersheyOrigin)
+	g := &Glyph{Advance: right - left}
+
+	var stroke ln.Path
+	for iX := 2; iX+1 < len(s); iX += 2 {
+		if s[iX] == ' ' && s[iX+1] == 'R' {
+			if len(stroke) > 1 {
+				g.Strokes = append(g.Strokes, stroke)
+			}
+			stroke = nil
+			continue
+		}
+		x := float64(int(s[iX])-hersheyOrigin) - left
+		y := float64(hersheyBaseline - (int(s[iX+1]) - hersheyOrigin))
+		stroke = append(stroke, ln.Vector{X: x, Y: y})
+	}
+	if len(stroke) > 1 {
+		g.Strokes = append(g.Strokes, stroke)
+	}
+	return g, nil
+}
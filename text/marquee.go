@@ -0,0 +1,212 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package text
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/ln/ln"
+)
+
+// Marquee scrolls a line of text right to left through a window. It is
+// a FrameSource and every frame has the same number of points, however
+// much text is in view, so the scan rate and brightness stay steady.
+type Marquee struct {
+	Text  string
+	Style Style
+	// X and Y are the middle of the window's baseline
+	X, Y float64
+	// Width of the window in DAC units
+	Width float64
+	// Speed in DAC units per frame
+	Speed float64
+	// Points in each frame, FramePoints() when 0
+	Points int
+	// BlankPoints are spent on each move between strokes
+	BlankPoints int
+
+	offset float64
+}
+
+// NewMarquee scrolls str through a window width DAC units wide,
+// centered on 0, 0
+func NewMarquee(str string, style Style, width float64) *Marquee {
+	return &Marquee{
+		Text:        str,
+		Style:       style,
+		Width:       width,
+		Speed:       200,
+		BlankPoints: 8,
+	}
+}
+
+// NextFrame draws the window and scrolls the text on. The text starts
+// just off the right edge and comes round again once it has left.
+func (m *Marquee) NextFrame(ctx context.Context) (etherdream.Frame, error) {
+	if err := ctx.Err(); err != nil {
+		return etherdream.Frame{}, err
+	}
+	st := m.Style
+	st.Align = AlignLeft
+	textWidth, _ := st.Measure(m.Text)
+
+	left, right := m.X-m.Width/2, m.X+m.Width/2
+	paths := clipX(st.Render(m.Text, right-m.offset, m.Y), left, right)
+
+	m.offset += m.Speed
+	if m.offset > textWidth+m.Width {
+		m.offset = 0
+	}
+
+	n := m.Points
+	if n <= 0 {
+		n = etherdream.FramePoints()
+	}
+	return etherdream.Frame{Points: samplePaths(paths, n, m.BlankPoints, ln.Vector{X: m.X, Y: m.Y})}, nil
+}
+
+// clipX cuts paths to the band between left and right
+func clipX(paths etherdream.ColorPaths, left, right float64) etherdream.ColorPaths {
+	var ret etherdream.ColorPaths
+	for _, cp := range paths {
+		var cur ln.Path
+		for iX := 1; iX < len(cp.Path); iX++ {
+			a, b := cp.Path[iX-1], cp.Path[iX]
+			t0, t1 := 0.0, 1.0
+			dx := b.X - a.X
+			if dx == 0 {
+				if a.X < left || a.X > right {
+					t0, t1 = 1, 0
+				}
+			} else {
+				tl, tr := (left-a.X)/dx, (right-a.X)/dx
+				if tl > tr {
+					tl, tr = tr, tl
+				}
+				t0, t1 = math.Max(t0, tl), math.Min(t1, tr)
+			}
+			if t0 > t1 {
+				// outside, finish what was inside
+				if len(cur) > 1 {
+					ret = append(ret, etherdream.ColorPath{Path: cur, Color: cp.Color})
+				}
+				cur = nil
+				continue
+			}
+			p0 := a.Add(b.Sub(a).MulScalar(t0))
+			p1 := a.Add(b.Sub(a).MulScalar(t1))
+			if len(cur) == 0 || t0 > 0 {
+				if len(cur) > 1 {
+					ret = append(ret, etherdream.ColorPath{Path: cur, Color: cp.Color})
+				}
+				cur = ln.Path{p0}
+			}
+			cur = append(cur, p1)
+			if t1 < 1 {
+				ret = append(ret, etherdream.ColorPath{Path: cur, Color: cp.Color})
+				cur = nil
+			}
+		}
+		if len(cur) > 1 {
+			ret = append(ret, etherdream.ColorPath{Path: cur, Color: cp.Color})
+		}
+	}
+	return ret
+}
+
+// samplePaths spreads exactly n points over paths, lit points in
+// proportion to each path's length and blank points on each move
+// between them. Paths that don't fit are dropped and spare points wait
+// at the end.
+func samplePaths(paths etherdream.ColorPaths, n, blank int, rest ln.Vector) []etherdream.Point {
+	// every path needs a move to it and at least two lit points
+	for len(paths) > 0 && len(paths)*(blank+2) > n {
+		paths = paths[:len(paths)-1]
+	}
+	pts := make([]etherdream.Point, 0, n)
+	if len(paths) == 0 {
+		for len(pts) < n {
+			pts = append(pts, *etherdream.NewPoint(int(rest.X), int(rest.Y), etherdream.BlankColor))
+		}
+		return pts
+	}
+
+	lengths := make([]float64, len(paths))
+	total := 0.0
+	for iX, cp := range paths {
+		for jX := 1; jX < len(cp.Path); jX++ {
+			lengths[iX] += cp.Path[jX].Distance(cp.Path[jX-1])
+		}
+		total += lengths[iX]
+	}
+
+	// share the lit points by largest remainder so they add up
+	lit := n - len(paths)*blank
+	counts := make([]int, len(paths))
+	spare := lit - 2*len(paths)
+	type share struct {
+		i    int
+		frac float64
+	}
+	shares := make([]share, len(paths))
+	used := 0
+	for iX := range paths {
+		f := 0.0
+		if total > 0 {
+			f = float64(spare) * lengths[iX] / total
+		}
+		counts[iX] = 2 + int(f)
+		used += int(f)
+		shares[iX] = share{iX, f - math.Floor(f)}
+	}
+	sort.Slice(shares, func(a, b int) bool { return shares[a].frac > shares[b].frac })
+	for iX := 0; used < spare && total > 0; iX++ {
+		counts[shares[iX%len(shares)].i]++
+		used++
+	}
+
+	for iX, cp := range paths {
+		start := cp.Path[0]
+		for jX := 0; jX < blank; jX++ {
+			pts = append(pts, *etherdream.NewPoint(int(start.X), int(start.Y), etherdream.BlankColor))
+		}
+		for jX := 0; jX < counts[iX]; jX++ {
+			v := alongPath(cp.Path, lengths[iX]*float64(jX)/float64(counts[iX]-1))
+			pts = append(pts, *etherdream.NewPoint(int(v.X), int(v.Y), cp.Color))
+		}
+	}
+	last := pts[len(pts)-1]
+	for len(pts) < n {
+		pts = append(pts, *etherdream.NewPoint(int(last.X), int(last.Y), etherdream.BlankColor))
+	}
+	return pts
+}
+
+// alongPath is the point d along path
+func alongPath(path ln.Path, d float64) ln.Vector {
+	for iX := 1; iX < len(path); iX++ {
+		l := path[iX].Distance(path[iX-1])
+		if d <= l && l > 0 {
+			return path[iX-1].Add(path[iX].Sub(path[iX-1]).MulScalar(d / l))
+		}
+		d -= l
+	}
+	return path[len(path)-1]
+}
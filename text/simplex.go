@@ -0,0 +1,117 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package text
+
+// simplexData is the Hershey roman simplex font for ASCII 32 to 126 in
+// JHF encoding, less the glyph number and vertex count.
+var simplexData = []string{
+	`JZ`,
+	`MWRFRT RRYQZR[SZRY`,
+	`JZNFNM RVFVM`,
+	`H]SBLb RYBRb RLOZO RKUYU`,
+	`H\PBP_ RTBT_ RYIWGTFPFMGKIKKLMMNOOUQWRXSYUYXWZT[P[MZKX`,
+	`F^[FI[ RNFPHPJOLMMKMIKIIJGLFNFPGSHVHYG[F RWTUUTWTYV[X[ZZ[X[VYTWT`,
+	`E_\O\N[MZMYNXPVUTXRZP[L[JZIYHWHUISJRQNRMSKSIRGPFNGMIMKNNPQUXWZY[[[\Z\Y`,
+	`NVRFRM`,
+	"KYVBTDRGPKOPOTPYR]T`Vb",
+	"KYNBPDRGTKUPUTTYR]P`Nb",
+	`JZRLRX RMOWU RWOMU`,
+	`E_RIR[ RIR[R`,
+	`NVSWRXQWRVSWSYQ[`,
+	`E_IR[R`,
+	`NVRVQWRXSWRV`,
+	`G][BIb`,
+	`H\QFNGLJKOKRLWNZQ[S[VZXWYRYOXJVGSFQF`,
+	`H\NJPISFS[`,
+	`H\LKLJMHNGPFTFVGWHXJXLWNUQK[Y[`,
+	`H\MFXFRNUNWOXPYSYUXXVZS[P[MZLYKW`,
+	`H\UFKTZT RUFU[`,
+	`H\WFMFLOMNPMSMVNXPYSYUXXVZS[P[MZLYKW`,
+	`H\XIWGTFRFOGMJLOLTMXOZR[S[VZXXYUYTXQVOSNRNOOMQLT`,
+	`H\YFO[ RKFYF`,
+	`H\PFMGLILKMMONSOVPXRYTYWXYWZT[P[MZLYKWKTLRNPQOUNWMXKXIWGTFPF`,
+	`H\XMWPURRSQSNRLPKMKLLINGQFRFUGWIXMXRWWUZR[P[MZLX`,
+	`NVROQPRQSPRO RRVQWRXSWRV`,
+	`NVROQPRQSPRO RSWRXQWRVSWSYQ[`,
+	`F^ZIJRZ[`,
+	`E_IO[O RIU[U`,
+	`F^JIZRJ[`,
+	`I[LKLJMHNGPFTFVGWHXJXLWNVORQRT RRYQZR[SZRY`,
+	"E`WNVLTKQKOLNMMPMSNUPVSVUUVS RQKOMNPNSOUPV RWKVSVUXVZV\\T]Q]O\\L[JYHWGTFQFNGLHJJILHOHRIUJWLYNZQ[T[WZYYZX RXKWSWUXV",
+	`I[RFJ[ RRFZ[ RMTWT`,
+	`G\KFK[ RKFTFWGXHYJYLXNWOTP RKPTPWQXRYTYWXYWZT[K[`,
+	`H]ZKYIWGUFQFOGMILKKNKSLVMXOZQ[U[WZYXZV`,
+	`G\KFK[ RKFRFUGWIXKYNYSXVWXUZR[K[`,
+	`H[LFL[ RLFYF RLPTP RL[Y[`,
+	`HZLFL[ RLFYF RLPTP`,
+	`H]ZKYIWGUFQFOGMILKKNKSLVMXOZQ[U[WZYXZVZS RUSZS`,
+	`G]KFK[ RYFY[ RKPYP`,
+	`NVRFR[`,
+	`JZVFVVUYTZR[P[NZMYLVLT`,
+	`G\KFK[ RYFKT RPOY[`,
+	`HYLFL[ RL[X[`,
+	`F^JFJ[ RJFR[ RZFR[ RZFZ[`,
+	`G]KFK[ RKFY[ RYFY[`,
+	`G]PFNGLIKKJNJSKVLXNZP[T[VZXXYVZSZNYKXIVGTFPF`,
+	`G\KFK[ RKFTFWGXHYJYMXOWPTQKQ`,
+	`G]PFNGLIKKJNJSKVLXNZP[T[VZXXYVZSZNYKXIVGTFPF RSWY]`,
+	`G\KFK[ RKFTFWGXHYJYLXNWOTPKP RRPY[`,
+	`H\YIWGTFPFMGKIKKLMMNOOUQWRXSYUYXWZT[P[MZKX`,
+	`JZRFR[ RKFYF`,
+	`G]KFKULXNZQ[S[VZXXYUYF`,
+	`I[JFR[ RZFR[`,
+	`F^HFM[ RRFM[ RRFW[ R\FW[`,
+	`H\KFY[ RYFK[`,
+	`I[JFRPR[ RZFRP`,
+	`H\YFK[ RKFYF RK[Y[`,
+	`KYOBOb RPBPb ROBVB RObVb`,
+	`KYKFY^`,
+	`KYTBTb RUBUb RNBUB RNbUb`,
+	`JZRDJR RRDZR`,
+	`I[Ib[b`,
+	`NVQFSK`,
+	`I\XMX[ RXPVNTMQMONMPLSLUMXOZQ[T[VZXX`,
+	`H[LFL[ RLPNNPMSMUNWPXSXUWXUZS[P[NZLX`,
+	`I[XPVNTMQMONMPLSLUMXOZQ[T[VZXX`,
+	`I\XFX[ RXPVNTMQMONMPLSLUMXOZQ[T[VZXX`,
+	`I[LSXSXQWOVNTMQMONMPLSLUMXOZQ[T[VZXX`,
+	`MYWFUFSGRJR[ ROMVM`,
+	"I\\XMX]W`VaTbQbOa RXPVNTMQMONMPLSLUMXOZQ[T[VZXX",
+	`I\MFM[ RMQPNRMUMWNXQX[`,
+	`NVQFRGSFREQF RRMR[`,
+	`MWRFSGTFSERF RSMS^RaPbNb`,
+	`IZMFM[ RWMMW RQSX[`,
+	`NVRFR[`,
+	`CaGMG[ RGQJNLMOMQNRQR[ RRQUNWMZM\N]Q][`,
+	`I\MMM[ RMQPNRMUMWNXQX[`,
+	`I\QMONMPLSLUMXOZQ[T[VZXXYUYSXPVNTMQM`,
+	`H[LMLb RLPNNPMSMUNWPXSXUWXUZS[P[NZLX`,
+	`I\XMXb RXPVNTMQMONMPLSLUMXOZQ[T[VZXX`,
+	`KXOMO[ ROSPPRNTMWM`,
+	`J[XPWNTMQMNNMPNRPSUTWUXWXXWZT[Q[NZMX`,
+	`MYRFRWSZU[W[ ROMVM`,
+	`I\MMMWNZP[S[UZXW RXMX[`,
+	`JZLMR[ RXMR[`,
+	`G]JMN[ RRMN[ RRMV[ RZMV[`,
+	`J[MMX[ RXMM[`,
+	`JZLMR[ RXMR[P_NaLbKb`,
+	`J[XMM[ RMMXM RM[X[`,
+	"KYTBRCQDPFPHQJRKSMSOQQ RRCQEQGRISJTLTNSPORSTTVTXSZR[Q]Q_Ra RQSSUSWRYQZP\\P^Q`RaTb",
+	`NVRBRb`,
+	"KYPBRCSDTFTHSJRKQMQOSQ RRCSESGRIQJPLPNQPURQTPVPXQZR[S]S_Ra RSSQUQWRYSZT\\T^S`RaPb",
+	`F^IUISJPLONOPPTSVTXTZS[Q RISJQLPNPPQTTVUXUZT[Q[O`,
+}
@@ -0,0 +1,225 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package text draws strings with single stroke Hershey fonts. Each
+// stroke becomes a colored ln path in DAC coordinates, DrawPaths blanks
+// between them.
+package text
+
+import (
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/ln/ln"
+)
+
+// Align places lines of text relative to the x position
+type Align int
+
+// Alignments
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+)
+
+// Style is how text is drawn
+type Style struct {
+	// Font defaults to Simplex
+	Font *Font
+	// Size is the height of a capital in DAC units, default 2000
+	Size float64
+	// Spacing is added between letters, in DAC units
+	Spacing float64
+	// LineHeight is the distance between baselines as a multiple of
+	// Size, default 1.6
+	LineHeight float64
+	Align      Align
+	// Color defaults to white
+	Color color.Color
+	// Colors picks the color of each glyph, i counts runes from the
+	// start of the string. It overrides Color when set.
+	Colors func(i int, r rune) color.Color
+}
+
+func (s Style) defaults() Style {
+	if s.Font == nil {
+		s.Font = Simplex
+	}
+	if s.Size == 0 {
+		s.Size = 2000
+	}
+	if s.LineHeight == 0 {
+		s.LineHeight = 1.6
+	}
+	if s.Color == nil {
+		s.Color = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	}
+	return s
+}
+
+func (s Style) scale() float64 {
+	return s.Size / CapHeight
+}
+
+func (s Style) color(i int, r rune) color.Color {
+	if s.Colors != nil {
+		return s.Colors(i, r)
+	}
+	return s.Color
+}
+
+// lineWidth is the width of one line in DAC units
+func (s Style) lineWidth(line string) float64 {
+	w := 0.0
+	n := 0
+	for _, r := range line {
+		if g := s.Font.Glyph(r); g != nil {
+			w += g.Advance * s.scale()
+			n++
+		}
+	}
+	if n > 1 {
+		w += s.Spacing * float64(n-1)
+	}
+	return w
+}
+
+// Measure returns the width of the widest line and the height from the
+// top of the first line's capitals to the last baseline
+func (s Style) Measure(str string) (width, height float64) {
+	s = s.defaults()
+	lines := strings.Split(str, "\n")
+	for _, l := range lines {
+		width = math.Max(width, s.lineWidth(l))
+	}
+	height = s.Size + float64(len(lines)-1)*s.Size*s.LineHeight
+	return width, height
+}
+
+// Render draws str with the first baseline at y. x is the left edge,
+// center or right edge of each line depending on Align. Lines are split
+// at newlines.
+func (s Style) Render(str string, x, y float64) etherdream.ColorPaths {
+	s = s.defaults()
+	var paths etherdream.ColorPaths
+	i := 0
+	for iX, line := range strings.Split(str, "\n") {
+		lx := x
+		switch s.Align {
+		case AlignCenter:
+			lx -= s.lineWidth(line) / 2
+		case AlignRight:
+			lx -= s.lineWidth(line)
+		}
+		ly := y - float64(iX)*s.Size*s.LineHeight
+		for _, r := range line {
+			g := s.Font.Glyph(r)
+			if g == nil {
+				i++
+				continue
+			}
+			c := s.color(i, r)
+			for _, st := range g.Strokes {
+				p := make(ln.Path, len(st))
+				for jX, v := range st {
+					p[jX] = clamp(ln.Vector{X: lx + v.X*s.scale(), Y: ly + v.Y*s.scale()})
+				}
+				paths = append(paths, etherdream.ColorPath{Path: p, Color: c})
+			}
+			lx += g.Advance*s.scale() + s.Spacing
+			i++
+		}
+		// the newline counts as a rune for Colors
+		i++
+	}
+	return paths
+}
+
+// OnPath draws a line of text along path, each glyph turned to follow
+// it. The baseline runs along the path, text starts offset DAC units in
+// and Align places it at the start, middle or end of the path. Glyphs
+// that run off the end carry on along the last segment.
+func (s Style) OnPath(str string, path ln.Path, offset float64) etherdream.ColorPaths {
+	s = s.defaults()
+	if len(path) < 2 {
+		return nil
+	}
+	str = strings.ReplaceAll(str, "\n", " ")
+	length := 0.0
+	for iX := 1; iX < len(path); iX++ {
+		length += path[iX].Distance(path[iX-1])
+	}
+	d := offset
+	switch s.Align {
+	case AlignCenter:
+		d = (length-s.lineWidth(str))/2 + offset
+	case AlignRight:
+		d = length - s.lineWidth(str) - offset
+	}
+
+	var paths etherdream.ColorPaths
+	for i, r := range []rune(str) {
+		g := s.Font.Glyph(r)
+		if g == nil {
+			continue
+		}
+		adv := g.Advance * s.scale()
+		// the glyph turns about the middle of its baseline
+		at, dir := pointAt(path, d+adv/2)
+		c := s.color(i, r)
+		for _, st := range g.Strokes {
+			p := make(ln.Path, len(st))
+			for jX, v := range st {
+				lx, ly := v.X*s.scale()-adv/2, v.Y*s.scale()
+				p[jX] = clamp(ln.Vector{
+					X: at.X + lx*dir.X - ly*dir.Y,
+					Y: at.Y + lx*dir.Y + ly*dir.X,
+				})
+			}
+			paths = append(paths, etherdream.ColorPath{Path: p, Color: c})
+		}
+		d += adv + s.Spacing
+	}
+	return paths
+}
+
+// pointAt finds the point d along path and the unit direction there
+func pointAt(path ln.Path, d float64) (ln.Vector, ln.Vector) {
+	var a, b ln.Vector
+	for iX := 1; iX < len(path); iX++ {
+		a, b = path[iX-1], path[iX]
+		l := a.Distance(b)
+		if l == 0 {
+			continue
+		}
+		if d <= l || iX == len(path)-1 {
+			dir := b.Sub(a).MulScalar(1 / l)
+			return a.Add(dir.MulScalar(d)), dir
+		}
+		d -= l
+	}
+	// every segment has zero length
+	return a, ln.Vector{X: 1}
+}
+
+func clamp(v ln.Vector) ln.Vector {
+	v.X = math.Max(-32767, math.Min(32767, v.X))
+	v.Y = math.Max(-32767, math.Min(32767, v.Y))
+	return v
+}
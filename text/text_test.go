@@ -0,0 +1,201 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package text
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/ln/ln"
+)
+
+func near(a, b ln.Vector) bool {
+	return a.Distance(b) < 1e-6
+}
+
+func TestSimplexGlyphs(t *testing.T) {
+	for _, c := range []struct {
+		r       rune
+		strokes int
+		advance float64
+		// bounds of the strokes
+		min, max ln.Vector
+	}{
+		{'A', 3, 18, ln.Vector{X: 1, Y: 0}, ln.Vector{X: 17, Y: 21}},
+		{'T', 2, 16, ln.Vector{X: 1, Y: 0}, ln.Vector{X: 15, Y: 21}},
+		{'I', 1, 8, ln.Vector{X: 4, Y: 0}, ln.Vector{X: 4, Y: 21}},
+		{' ', 0, 16, ln.Vector{}, ln.Vector{}},
+	} {
+		g := Simplex.Glyph(c.r)
+		if len(g.Strokes) != c.strokes || g.Advance != c.advance {
+			t.Errorf("%q: %v strokes advancing %v, want %v advancing %v", c.r, len(g.Strokes), g.Advance, c.strokes, c.advance)
+			continue
+		}
+		if c.strokes == 0 {
+			continue
+		}
+		min := ln.Vector{X: math.Inf(1), Y: math.Inf(1)}
+		max := ln.Vector{X: math.Inf(-1), Y: math.Inf(-1)}
+		for _, st := range g.Strokes {
+			for _, v := range st {
+				min = ln.Vector{X: math.Min(min.X, v.X), Y: math.Min(min.Y, v.Y)}
+				max = ln.Vector{X: math.Max(max.X, v.X), Y: math.Max(max.Y, v.Y)}
+			}
+		}
+		if min != c.min || max != c.max {
+			t.Errorf("%q: bounds %v to %v, want %v to %v", c.r, min, max, c.min, c.max)
+		}
+	}
+
+	// the crossbar of the A, y is up from the baseline
+	bar := Simplex.Glyph('A').Strokes[2]
+	if len(bar) != 2 || bar[0] != (ln.Vector{X: 4, Y: 7}) || bar[1] != (ln.Vector{X: 14, Y: 7}) {
+		t.Errorf("A crossbar %v, want 4, 7 to 14, 7", bar)
+	}
+	if Simplex.Glyph('☃') != Simplex.Glyph('?') {
+		t.Errorf("missing glyphs aren't drawn as ?")
+	}
+}
+
+func TestParseJHF(t *testing.T) {
+	// the first line is cut short and carries on over the next
+	jhf := "    1  1JZ\n\n  501  9I[RFJ[ RRFZ[\n RMTWT\n"
+	f, err := ParseJHF(strings.NewReader(jhf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := f.Glyph(' '); g.Advance != 16 || len(g.Strokes) != 0 {
+		t.Errorf("space %+v", g)
+	}
+	if g := f.Glyph('!'); len(g.Strokes) != 3 || g.Advance != 18 {
+		t.Errorf("second glyph has %v strokes advancing %v, want the A", len(g.Strokes), g.Advance)
+	}
+
+	for _, bad := range []string{"short\n", "    1  xJZ\n", "    1  9JZ\n"} {
+		if _, err := ParseJHF(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q parsed, want an error", bad)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	// at size 21 a font unit is a DAC unit
+	st := Style{Size: CapHeight, Spacing: 2}
+	paths := st.Render("TA", 100, 50)
+	if len(paths) != 5 {
+		t.Fatalf("%v paths, want 5", len(paths))
+	}
+	// the T's stem, then the A starts after its advance and spacing
+	if p := paths[0].Path; !near(p[0], ln.Vector{X: 108, Y: 71}) || !near(p[1], ln.Vector{X: 108, Y: 50}) {
+		t.Errorf("T stem %v", p)
+	}
+	if p := paths[2].Path; !near(p[0], ln.Vector{X: 127, Y: 71}) {
+		t.Errorf("A starts at %v, want 127, 71", p[0])
+	}
+
+	if w, h := st.Measure("TA\nI"); w != 36 || h != CapHeight*2.6 {
+		t.Errorf("Measure = %v, %v, want 36, %v", w, h, CapHeight*2.6)
+	}
+
+	st.Align = AlignCenter
+	if p := st.Render("I", 0, 0)[0].Path; !near(p[0], ln.Vector{X: 0, Y: 21}) {
+		t.Errorf("centred I at %v, want 0, 21", p[0])
+	}
+	st.Align = AlignRight
+	if p := st.Render("I", 0, 0)[0].Path; !near(p[0], ln.Vector{X: -4, Y: 21}) {
+		t.Errorf("right aligned I at %v, want -4, 21", p[0])
+	}
+}
+
+func TestOnPath(t *testing.T) {
+	st := Style{Size: CapHeight, Spacing: 2}
+	str := "TAI"
+	flat := st.Render(str, 500, 0)
+
+	// along a straight line to the right it matches Render
+	along := st.OnPath(str, ln.Path{{}, {X: 10000}}, 500)
+	// and up a line it's turned a quarter to the left
+	up := st.OnPath(str, ln.Path{{}, {Y: 10000}}, 500)
+	if len(along) != len(flat) || len(up) != len(flat) {
+		t.Fatalf("%v and %v paths, want %v", len(along), len(up), len(flat))
+	}
+	for iX, cp := range flat {
+		for jX, v := range cp.Path {
+			if !near(along[iX].Path[jX], v) {
+				t.Errorf("along: path %v point %v at %v, want %v", iX, jX, along[iX].Path[jX], v)
+			}
+			if want := (ln.Vector{X: -v.Y, Y: v.X}); !near(up[iX].Path[jX], want) {
+				t.Errorf("up: path %v point %v at %v, want %v", iX, jX, up[iX].Path[jX], want)
+			}
+		}
+	}
+
+	// centred on a path as long as the text, the glyphs go round its
+	// corner
+	st.Align = AlignCenter
+	w, _ := st.Measure(str)
+	corner := st.OnPath(str, ln.Path{{}, {X: 30}, {X: 30, Y: w - 30}}, 0)
+	if p := corner[0].Path; !near(p[0], ln.Vector{X: 8, Y: 21}) {
+		t.Errorf("T starts at %v, want 8, 21", p[0])
+	}
+	// the middle of the I is 12 past the corner, and it is on its side
+	if p := corner[len(corner)-1].Path; !near(p[0], ln.Vector{X: 9, Y: 12}) || !near(p[1], ln.Vector{X: 30, Y: 12}) {
+		t.Errorf("I runs %v to %v, want 9, 12 to 30, 12", p[0], p[1])
+	}
+}
+
+func TestMarquee(t *testing.T) {
+	m := NewMarquee("ETHER", Style{Size: 2000}, 10000)
+	m.Points = 500
+	m.Speed = 1000
+	ctx := context.Background()
+	lit := 0
+	for iX := 0; iX < 30; iX++ {
+		f, err := m.NextFrame(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Points) != m.Points {
+			t.Fatalf("frame %v has %v points, want %v", iX, len(f.Points), m.Points)
+		}
+		for _, pt := range f.Points {
+			if pt.R == 0 && pt.G == 0 && pt.B == 0 {
+				continue
+			}
+			lit++
+			if pt.X < -5001 || pt.X > 5001 {
+				t.Fatalf("frame %v lit point at x %v, outside the window", iX, pt.X)
+			}
+		}
+	}
+	if lit == 0 {
+		t.Errorf("the text never came into view")
+	}
+
+	// the first frame starts off the right edge, all blank
+	m = NewMarquee("ETHER", Style{}, 10000)
+	m.Points = 100
+	f, _ := m.NextFrame(ctx)
+	for _, pt := range f.Points {
+		if pt != *etherdream.NewPoint(0, 0, etherdream.BlankColor) {
+			t.Fatalf("empty window point %+v, want blank at the centre", pt)
+		}
+	}
+}
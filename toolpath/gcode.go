@@ -0,0 +1,218 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package toolpath
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// gcode is the modal state of the machine
+type gcode struct {
+	motion   int
+	inches   bool
+	relative bool
+}
+
+// ParseGCode reads a G-code job in the XY plane. G0 rapids are blank,
+// G1 feeds and G2/G3 arcs are lit. G20/G21 select inches or millimetres
+// and G90/G91 absolute or relative positions. Z and other words are
+// skipped.
+func ParseGCode(r io.Reader, opts Options) (etherdream.ColorPaths, error) {
+	b, err := newBuilder(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	st := gcode{}
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		words, err := gcodeWords(sc.Text())
+		if err != nil {
+			return nil, fmt.Errorf("toolpath: %v on G-code line %d", err, line)
+		}
+		if err := st.run(b, words); err != nil {
+			return nil, fmt.Errorf("toolpath: %v on G-code line %d", err, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return b.result(), nil
+}
+
+// word is a letter and its number, like G1 or X12.5
+type word struct {
+	letter byte
+	value  float64
+}
+
+// gcodeWords splits a line into words, dropping comments
+func gcodeWords(s string) ([]word, error) {
+	var words []word
+	for iX := 0; iX < len(s); {
+		c := s[iX]
+		switch {
+		case c == ';' || c == '%':
+			return words, nil
+		case c == '(':
+			end := strings.IndexByte(s[iX:], ')')
+			if end < 0 {
+				return words, nil
+			}
+			iX += end + 1
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			iX++
+			continue
+		}
+		if !isLetter(c) {
+			return nil, fmt.Errorf("unexpected %q", c)
+		}
+		iX++
+		start := iX
+		for iX < len(s) && strings.IndexByte("+-.0123456789 ", s[iX]) >= 0 {
+			iX++
+		}
+		v, err := strconv.ParseFloat(strings.ReplaceAll(s[start:iX], " ", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number after %c", c)
+		}
+		words = append(words, word{c &^ 0x20, v})
+	}
+	return words, nil
+}
+
+// run applies one line. Modal codes take effect before the move on
+// the same line.
+func (st *gcode) run(b *builder, words []word) error {
+	var x, y, i, j, rad *float64
+	for iX := range words {
+		w := &words[iX]
+		switch w.letter {
+		case 'G':
+			switch w.value {
+			case 0, 1, 2, 3:
+				st.motion = int(w.value)
+			case 20:
+				st.inches = true
+			case 21:
+				st.inches = false
+			case 90:
+				st.relative = false
+			case 91:
+				st.relative = true
+			}
+		case 'X':
+			x = &w.value
+		case 'Y':
+			y = &w.value
+		case 'I':
+			i = &w.value
+		case 'J':
+			j = &w.value
+		case 'R':
+			rad = &w.value
+		}
+	}
+	if x == nil && y == nil {
+		return nil
+	}
+
+	unit := 1.0
+	if st.inches {
+		unit = 25.4
+	}
+	tx, ty := b.x, b.y
+	if st.relative {
+		if x != nil {
+			tx += *x * unit
+		}
+		if y != nil {
+			ty += *y * unit
+		}
+	} else {
+		if x != nil {
+			tx = *x * unit
+		}
+		if y != nil {
+			ty = *y * unit
+		}
+	}
+
+	switch st.motion {
+	case 0:
+		b.moveTo(tx, ty)
+	case 1:
+		b.lineTo(tx, ty)
+	case 2, 3:
+		cw := st.motion == 2
+		// I and J are always relative to the start
+		if i != nil || j != nil {
+			var cx, cy float64
+			if i != nil {
+				cx = *i * unit
+			}
+			if j != nil {
+				cy = *j * unit
+			}
+			b.arcTo(tx, ty, b.x+cx, b.y+cy, cw)
+			return nil
+		}
+		if rad == nil {
+			return fmt.Errorf("arc needs I, J or R")
+		}
+		cx, cy, err := radiusCenter(b.x, b.y, tx, ty, *rad*unit, cw)
+		if err != nil {
+			return err
+		}
+		b.arcTo(tx, ty, cx, cy, cw)
+	}
+	return nil
+}
+
+// radiusCenter finds the center of an arc given by its radius. A
+// negative radius picks the arc longer than a half circle.
+func radiusCenter(x0, y0, x1, y1, r float64, cw bool) (float64, float64, error) {
+	dx, dy := x1-x0, y1-y0
+	d := math.Hypot(dx, dy)
+	if d == 0 {
+		return 0, 0, fmt.Errorf("R arc needs distinct end points")
+	}
+	h2 := r*r - d*d/4
+	if h2 < 0 {
+		// allow for rounding in the file
+		if h2 < -1e-6*r*r {
+			return 0, 0, fmt.Errorf("R %v is too small for the arc", r)
+		}
+		h2 = 0
+	}
+	h := math.Sqrt(h2)
+	// the short counter clockwise arc has its center to the left
+	if cw != (r < 0) {
+		h = -h
+	}
+	return x0 + dx/2 - dy/d*h, y0 + dy/2 + dx/d*h, nil
+}
@@ -0,0 +1,150 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package toolpath
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tgreiser/etherdream"
+)
+
+// HPGLUnits is the number of plotter units in a millimetre
+const HPGLUnits = 40.0
+
+// hpglOps are the instructions ParseHPGL acts on
+var hpglOps = map[string]bool{"IN": true, "SP": true, "PU": true, "PD": true, "PA": true, "PR": true}
+
+// ParseHPGL reads an HPGL plot. PU, PD, PA, PR and SP are drawn, IN
+// resets the pen and other instructions are skipped.
+func ParseHPGL(r io.Reader, opts Options) (etherdream.ColorPaths, error) {
+	b, err := newBuilder(opts)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	down, relative := false, false
+	s := string(data)
+	for iX := 0; iX < len(s); {
+		c := s[iX]
+		if !isLetter(c) {
+			iX++
+			continue
+		}
+		if iX+1 >= len(s) || !isLetter(s[iX+1]) {
+			return nil, fmt.Errorf("toolpath: bad HPGL instruction at offset %d", iX)
+		}
+		op := strings.ToUpper(s[iX : iX+2])
+		iX += 2
+
+		// labels run to the end of text character
+		if op == "LB" {
+			end := strings.IndexByte(s[iX:], 0x03)
+			if end < 0 {
+				break
+			}
+			iX += end + 1
+			continue
+		}
+
+		start := iX
+		for iX < len(s) && !isLetter(s[iX]) && s[iX] != ';' {
+			iX++
+		}
+		if !hpglOps[op] {
+			continue
+		}
+		params, err := hpglParams(s[start:iX])
+		if err != nil {
+			return nil, fmt.Errorf("toolpath: %v in HPGL %v at offset %d", err, op, start)
+		}
+
+		switch op {
+		case "IN":
+			down, relative = false, false
+			b.setColor(b.opts.Color)
+			b.moveTo(0, 0)
+			continue
+		case "SP":
+			pen := 0
+			if len(params) > 0 {
+				pen = int(params[0])
+			}
+			// pen 0 puts the pen away
+			if pen == 0 {
+				down = false
+				b.end()
+				continue
+			}
+			c, ok := b.opts.Pens[pen]
+			if !ok {
+				c = b.opts.Color
+			}
+			b.setColor(c)
+			continue
+		case "PU":
+			down = false
+			b.end()
+		case "PD":
+			down = true
+		case "PA":
+			relative = false
+		case "PR":
+			relative = true
+		}
+
+		// the pen and move instructions take coordinate pairs
+		for jX := 0; jX+1 < len(params); jX += 2 {
+			x, y := params[jX]/HPGLUnits, params[jX+1]/HPGLUnits
+			if relative {
+				x, y = b.x+x, b.y+y
+			}
+			if down {
+				b.lineTo(x, y)
+			} else {
+				b.moveTo(x, y)
+			}
+		}
+	}
+	return b.result(), nil
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+// hpglParams splits numbers separated by commas or spaces
+func hpglParams(s string) ([]float64, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+	ret := make([]float64, 0, len(fields))
+	for _, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", f)
+		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
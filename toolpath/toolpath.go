@@ -0,0 +1,169 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package toolpath reads HPGL plotter files and G-code CNC jobs as
+// colored ln paths, to preview them with the laser. Pen down and feed
+// moves become lit paths, pen up and rapid moves end a path so
+// DrawPaths blanks across them.
+package toolpath
+
+import (
+	"errors"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/ln/ln"
+)
+
+// Options for reading a toolpath
+type Options struct {
+	// Scale is the number of DAC units per millimetre
+	Scale float64
+	// OriginX and OriginY, in millimetres, map to DAC 0, 0
+	OriginX float64
+	OriginY float64
+	// Color of lit moves, white when nil
+	Color color.Color
+	// Pens gives HPGL pens their own colors, other pens use Color
+	Pens map[int]color.Color
+	// Tolerance is the furthest arcs may stray from the real curve, in
+	// DAC units. Defaults to 40.
+	Tolerance float64
+}
+
+// ErrNoScale is returned when Options.Scale is not set
+var ErrNoScale = errors.New("toolpath: Options.Scale must be set")
+
+// Load reads the named file, as HPGL for .plt, .hpgl and .hpg files and
+// as G-code otherwise
+func Load(name string, opts Options) (etherdream.ColorPaths, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".plt", ".hpgl", ".hpg":
+		return ParseHPGL(f, opts)
+	}
+	return ParseGCode(f, opts)
+}
+
+// builder collects lit moves into paths. Positions are in millimetres.
+type builder struct {
+	opts  Options
+	color color.Color
+	paths etherdream.ColorPaths
+	cur   ln.Path
+	x, y  float64
+}
+
+func newBuilder(opts Options) (*builder, error) {
+	if opts.Scale == 0 {
+		return nil, ErrNoScale
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = 40
+	}
+	if opts.Color == nil {
+		opts.Color = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	}
+	return &builder{opts: opts, color: opts.Color}, nil
+}
+
+// vector maps a position to DAC coordinates
+func (b *builder) vector(x, y float64) ln.Vector {
+	clamp := func(v float64) float64 {
+		return math.Max(-32767, math.Min(32767, v))
+	}
+	return ln.Vector{
+		X: clamp((x - b.opts.OriginX) * b.opts.Scale),
+		Y: clamp((y - b.opts.OriginY) * b.opts.Scale),
+	}
+}
+
+// setColor ends the current path when the color changes
+func (b *builder) setColor(c color.Color) {
+	if c != b.color {
+		b.end()
+		b.color = c
+	}
+}
+
+// moveTo is an unlit move
+func (b *builder) moveTo(x, y float64) {
+	if x != b.x || y != b.y {
+		b.end()
+	}
+	b.x, b.y = x, y
+}
+
+// lineTo is a lit move
+func (b *builder) lineTo(x, y float64) {
+	if len(b.cur) == 0 {
+		b.cur = ln.Path{b.vector(b.x, b.y)}
+	}
+	b.cur = append(b.cur, b.vector(x, y))
+	b.x, b.y = x, y
+}
+
+// arcTo is a lit arc about cx, cy to x, y, clockwise when cw is set
+func (b *builder) arcTo(x, y, cx, cy float64, cw bool) {
+	r := math.Hypot(b.x-cx, b.y-cy)
+	start := math.Atan2(b.y-cy, b.x-cx)
+	end := math.Atan2(y-cy, x-cx)
+	sweep := end - start
+	if cw {
+		for sweep >= 0 {
+			sweep -= 2 * math.Pi
+		}
+	} else {
+		for sweep <= 0 {
+			sweep += 2 * math.Pi
+		}
+	}
+
+	n := 4
+	rd := r * math.Abs(b.opts.Scale)
+	if rd > b.opts.Tolerance {
+		// the sagitta of a chord spanning angle a is r(1-cos(a/2))
+		a := 2 * math.Acos(1-b.opts.Tolerance/rd)
+		n = int(math.Max(4, math.Ceil(math.Abs(sweep)/a)))
+	}
+	for iX := 1; iX < n; iX++ {
+		t := start + sweep*float64(iX)/float64(n)
+		b.lineTo(cx+r*math.Cos(t), cy+r*math.Sin(t))
+	}
+	b.lineTo(x, y)
+}
+
+// end finishes the current path
+func (b *builder) end() {
+	if len(b.cur) > 1 {
+		b.paths = append(b.paths, etherdream.ColorPath{Path: b.cur, Color: b.color})
+	}
+	b.cur = nil
+}
+
+func (b *builder) result() etherdream.ColorPaths {
+	b.end()
+	return b.paths
+}
@@ -0,0 +1,214 @@
+/*
+# Copyright 2016 Tim Greiser
+
+# This program is free software: you can redistribute it and/or modify
+# it under the terms of the GNU General Public License as published by
+# the Free Software Foundation, version 3.
+#
+# This program is distributed in the hope that it will be useful,
+# but WITHOUT ANY WARRANTY; without even the implied warranty of
+# MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+# GNU General Public License for more details.
+#
+# You should have received a copy of the GNU General Public License
+# along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package toolpath
+
+import (
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/tgreiser/etherdream"
+	"github.com/tgreiser/ln/ln"
+)
+
+func near(a, b ln.Vector) bool {
+	return a.Distance(b) < 1e-6
+}
+
+func samePath(got, want ln.Path) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for iX := range got {
+		if !near(got[iX], want[iX]) {
+			return false
+		}
+	}
+	return true
+}
+
+func samePaths(got, want []ln.Path) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for iX := range got {
+		if !samePath(got[iX], want[iX]) {
+			return false
+		}
+	}
+	return true
+}
+
+func path(xy ...float64) ln.Path {
+	var p ln.Path
+	for iX := 0; iX+1 < len(xy); iX += 2 {
+		p = append(p, ln.Vector{X: xy[iX], Y: xy[iX+1]})
+	}
+	return p
+}
+
+func paths(cps etherdream.ColorPaths) []ln.Path {
+	ret := make([]ln.Path, len(cps))
+	for iX, cp := range cps {
+		ret[iX] = cp.Path
+	}
+	return ret
+}
+
+func TestHPGL(t *testing.T) {
+	// 40 plotter units to the millimetre and 1 DAC unit to the
+	// millimetre
+	for _, c := range []struct {
+		plt  string
+		want []ln.Path
+	}{
+		{"IN;PU0,0;PD400,0,400,400;PU;", []ln.Path{path(0, 0, 10, 0, 10, 10)}},
+		{"IN;PA;PU400,0;PD0,0;PU0,400;PD400,400;", []ln.Path{path(10, 0, 0, 0), path(0, 10, 10, 10)}},
+		{"IN;PU400,400;PR;PD400,0 0,400;", []ln.Path{path(10, 10, 20, 10, 20, 20)}},
+		{"IN;PR;PU40,40;PD40,0;PA;PD0,0;", []ln.Path{path(1, 1, 2, 1, 0, 0)}},
+		// IN puts the pen up and goes back to absolute at 0, 0
+		{"PR;PD400,0;IN;PD0,400;", []ln.Path{path(0, 0, 10, 0), path(0, 0, 0, 10)}},
+		// other instructions and labels are skipped
+		{"IN;VS10;LBPU PD 9,9\x03;PD400,0;", []ln.Path{path(0, 0, 10, 0)}},
+		{"in;pd400,0;", []ln.Path{path(0, 0, 10, 0)}},
+	} {
+		got, err := ParseHPGL(strings.NewReader(c.plt), Options{Scale: 1})
+		if err != nil {
+			t.Errorf("%q: %v", c.plt, err)
+			continue
+		}
+		if gp := paths(got); !samePaths(gp, c.want) {
+			t.Errorf("%q: got %v, want %v", c.plt, gp, c.want)
+		}
+	}
+
+	for _, bad := range []string{"IN;P;", "IN;PD1,x;"} {
+		if _, err := ParseHPGL(strings.NewReader(bad), Options{Scale: 1}); err == nil {
+			t.Errorf("%q parsed, want an error", bad)
+		}
+	}
+	if _, err := ParseHPGL(strings.NewReader("IN;"), Options{}); err != ErrNoScale {
+		t.Errorf("no scale: err = %v, want ErrNoScale", err)
+	}
+}
+
+func TestHPGLPens(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	def := color.RGBA{0, 0, 0xff, 0xff}
+	got, err := ParseHPGL(strings.NewReader("IN;SP1;PD400,0;SP2;PD400,400;SP0;PD0,400;"),
+		Options{Scale: 1, Color: def, Pens: map[int]color.Color{1: red}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("%v paths, want one for each pen", len(got))
+	}
+	for iX, c := range []color.Color{red, def, def} {
+		if got[iX].Color != c {
+			t.Errorf("path %v color %v, want %v", iX, got[iX].Color, c)
+		}
+	}
+}
+
+// onCircle checks every point of p is r from c
+func onCircle(t *testing.T, name string, p ln.Path, c ln.Vector, r float64) {
+	t.Helper()
+	for _, v := range p {
+		if d := v.Distance(c); math.Abs(d-r) > 1e-6 {
+			t.Errorf("%v: point %v is %.2f from the centre, want %v", name, v, d, r)
+			return
+		}
+	}
+}
+
+func TestGCodeMoves(t *testing.T) {
+	for _, c := range []struct {
+		nc   string
+		want []ln.Path
+	}{
+		{"G0 X0 Y0\nG1 X10\nY10\nG0 X20\nG1 Y0", []ln.Path{path(0, 0, 10, 0, 10, 10), path(20, 10, 20, 0)}},
+		// the motion is modal, X on its own carries on feeding
+		{"G1 X10 Y0\nX10 Y10\nX0", []ln.Path{path(0, 0, 10, 0, 10, 10, 0, 10)}},
+		{"G91\nG0 X5 Y5\nG1 X5\nY5", []ln.Path{path(5, 5, 10, 5, 10, 10)}},
+		{"G20 G1 X1\nG21 X10", []ln.Path{path(0, 0, 25.4, 0, 10, 0)}},
+		{"N10 G1 X10 (cut) F200 ; feed\n%\nG1 Y10 Z-1", []ln.Path{path(0, 0, 10, 0, 10, 10)}},
+		{"g1x10y10", []ln.Path{path(0, 0, 10, 10)}},
+	} {
+		got, err := ParseGCode(strings.NewReader(c.nc), Options{Scale: 1})
+		if err != nil {
+			t.Errorf("%q: %v", c.nc, err)
+			continue
+		}
+		if gp := paths(got); !samePaths(gp, c.want) {
+			t.Errorf("%q: got %v, want %v", c.nc, gp, c.want)
+		}
+	}
+
+	for _, bad := range []string{"G1 X1.2.3", "G1 #1", "G0 X10\nG2 X0 Y10", "G0 X10\nG2 X-10 R1"} {
+		if _, err := ParseGCode(strings.NewReader(bad), Options{Scale: 1}); err == nil {
+			t.Errorf("%q parsed, want an error", bad)
+		}
+	}
+}
+
+func TestGCodeArcs(t *testing.T) {
+	for _, c := range []struct {
+		nc string
+		// a point the arc passes through
+		via ln.Vector
+	}{
+		// counter clockwise a quarter around 0, 0
+		{"G0 X10 Y0\nG3 X0 Y10 I-10 J0", ln.Vector{X: 10 * math.Sqrt2 / 2, Y: 10 * math.Sqrt2 / 2}},
+		// clockwise the long way round to the same place
+		{"G0 X10 Y0\nG2 X0 Y10 I-10 J0", ln.Vector{X: 0, Y: -10}},
+		{"G0 X10 Y0\nG3 X0 Y10 R10", ln.Vector{X: 10 * math.Sqrt2 / 2, Y: 10 * math.Sqrt2 / 2}},
+		// a negative radius takes the long way
+		{"G0 X10 Y0\nG2 X0 Y10 R-10", ln.Vector{X: 0, Y: -10}},
+		{"G0 X10 Y0\nG91 G3 X-10 Y10 I-10", ln.Vector{X: 10 * math.Sqrt2 / 2, Y: 10 * math.Sqrt2 / 2}},
+	} {
+		got, err := ParseGCode(strings.NewReader(c.nc), Options{Scale: 100, Tolerance: 1})
+		if err != nil {
+			t.Errorf("%q: %v", c.nc, err)
+			continue
+		}
+		if len(got) != 1 {
+			t.Errorf("%q: %v paths, want 1", c.nc, len(got))
+			continue
+		}
+		p := got[0].Path
+		onCircle(t, c.nc, p, ln.Vector{}, 1000)
+		if !near(p[0], ln.Vector{X: 1000}) || !near(p[len(p)-1], ln.Vector{Y: 1000}) {
+			t.Errorf("%q: runs %v to %v", c.nc, p[0], p[len(p)-1])
+		}
+		// within the tolerance of the point on the way
+		via := c.via.MulScalar(100)
+		best := math.Inf(1)
+		for iX := 1; iX < len(p); iX++ {
+			best = math.Min(best, distToSegment(via, p[iX-1], p[iX]))
+		}
+		if best > 1 {
+			t.Errorf("%q: misses %v by %.1f", c.nc, via, best)
+		}
+	}
+}
+
+func distToSegment(p, a, b ln.Vector) float64 {
+	d := b.Sub(a)
+	t := math.Max(0, math.Min(1, p.Sub(a).Dot(d)/d.Dot(d)))
+	return p.Distance(a.Add(d.MulScalar(t)))
+}